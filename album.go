@@ -0,0 +1,160 @@
+// sending multiple outputs of a script as albums (media groups)
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	bot "github.com/meinside/telegram-bot-go"
+)
+
+const (
+	albumMarker   = "#ALBUM:" // output of a script, followed by the path of a directory with files to be sent
+	maxAlbumItems = 10        // max number of items in a media group
+)
+
+// AlbumItem struct for a file to be sent in an album
+type AlbumItem struct {
+	Path string
+	Mime string
+}
+
+// albumMedia struct for an item of a media group
+//
+// (bot.InputMedia always includes an empty thumbnail, so media are marshaled with this struct instead)
+type albumMedia struct {
+	Type    bot.InputMediaType `json:"type"`
+	Media   string             `json:"media"`
+	Caption string             `json:"caption,omitempty"`
+}
+
+// get the directory of an album from the output of a script
+//
+// eg. "#ALBUM: /tmp/panorama"
+func albumDir(output []byte) (dir string, isAlbum bool) {
+	text := strings.TrimSpace(string(output))
+	if !strings.HasPrefix(text, albumMarker) || strings.Contains(text, "\n") {
+		return "", false
+	}
+
+	return strings.TrimSpace(strings.TrimPrefix(text, albumMarker)), true
+}
+
+// read images and videos in given directory, sorted by their names
+func readAlbum(dir string) ([]AlbumItem, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Name() < files[j].Name()
+	})
+
+	items := []AlbumItem{}
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+
+		path := filepath.Join(dir, file.Name())
+		bytes, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		mime := detectContentType(bytes)
+		if isPhoto(mime) || strings.HasPrefix(mime, "video") {
+			items = append(items, AlbumItem{Path: path, Mime: mime})
+		} else {
+			log.Printf("*** Skipping file of unsupported type in album: %s (%s)", path, mime)
+		}
+	}
+	if len(items) <= 0 {
+		return nil, fmt.Errorf("no images or videos in %s", dir)
+	}
+
+	return items, nil
+}
+
+// remove the directory of an album, if it is a temporary one
+func removeAlbum(dir string) {
+	if rel, err := filepath.Rel(tempDir, dir); err == nil && !strings.HasPrefix(rel, "..") && rel != "." {
+		if err := os.RemoveAll(dir); err != nil {
+			log.Printf("*** Failed to remove album directory: %s", err)
+		}
+	}
+}
+
+// send given items as albums of up to 10 items (caption is put on the first item)
+func sendAlbum(b BotClient, chatID bot.ChatID, items []AlbumItem, caption string, options map[string]interface{}) error {
+	for start := 0; start < len(items); start += maxAlbumItems {
+		end := start + maxAlbumItems
+		if end > len(items) {
+			end = len(items)
+		}
+		chunk := items[start:end]
+
+		// copy options (without reply markup, which is not supported by media groups)
+		opts := map[string]interface{}{}
+		for k, v := range options {
+			if k != "reply_markup" {
+				opts[k] = v
+			}
+		}
+
+		// a media group needs at least 2 items
+		if len(chunk) == 1 {
+			item := chunk[0]
+			opts = optionsWithCaption(opts, caption)
+
+			var sent bot.APIResponseMessage
+			if strings.HasPrefix(item.Mime, "video") {
+				sent = b.SendVideo(chatID, bot.InputFileFromFilepath(item.Path), opts)
+			} else {
+				sent = b.SendPhoto(chatID, bot.InputFileFromFilepath(item.Path), opts)
+			}
+			if !sent.Ok {
+				return fmt.Errorf("%s", *sent.Description)
+			}
+			continue
+		}
+
+		media := []albumMedia{}
+		for i, item := range chunk {
+			name := fmt.Sprintf("file%d", i)
+
+			m := albumMedia{
+				Type:  bot.InputMediaPhoto,
+				Media: "attach://" + name,
+			}
+			if strings.HasPrefix(item.Mime, "video") {
+				m.Type = bot.InputMediaVideo
+			}
+			if start == 0 && i == 0 {
+				m.Caption = caption
+			}
+			media = append(media, m)
+
+			opts[name] = bot.InputFileFromFilepath(item.Path)
+		}
+
+		marshaled, err := json.Marshal(media)
+		if err != nil {
+			return err
+		}
+		opts["media"] = string(marshaled) // overrides the media parameter
+
+		if sent := b.SendMediaGroup(chatID, []bot.InputMedia{}, opts); !sent.Ok {
+			return fmt.Errorf("%s", *sent.Description)
+		}
+	}
+
+	return nil
+}
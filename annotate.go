@@ -0,0 +1,100 @@
+// annotating captured images with texts
+
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	_ "image/png" // for decoding png images
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	annotationPadding     = 6  // in pixels
+	annotationJpegQuality = 90 // quality of annotated jpeg images
+)
+
+// wrap given text into lines which fit in given number of characters
+func wrapText(text string, maxChars int) []string {
+	if maxChars <= 0 {
+		maxChars = 1
+	}
+
+	lines := []string{}
+	line := ""
+	for _, word := range strings.Fields(text) {
+		// split words longer than a line
+		for len([]rune(word)) > maxChars {
+			if len(line) > 0 {
+				lines = append(lines, line)
+				line = ""
+			}
+			runes := []rune(word)
+			lines = append(lines, string(runes[:maxChars]))
+			word = string(runes[maxChars:])
+		}
+
+		if len(line) <= 0 {
+			line = word
+		} else if len([]rune(line))+1+len([]rune(word)) <= maxChars {
+			line += " " + word
+		} else {
+			lines = append(lines, line)
+			line = word
+		}
+	}
+	if len(line) > 0 {
+		lines = append(lines, line)
+	}
+
+	return lines
+}
+
+// burn given text onto the bottom of an image, and return it as jpeg bytes
+func annotateImage(imageBytes []byte, text string) ([]byte, error) {
+	src, _, err := image.Decode(bytes.NewReader(imageBytes))
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := src.Bounds()
+	img := image.NewRGBA(bounds)
+	draw.Draw(img, bounds, src, bounds.Min, draw.Src)
+
+	face := basicfont.Face7x13
+	lineHeight := face.Metrics().Height.Ceil()
+	maxChars := (bounds.Dx() - annotationPadding*2) / face.Advance
+	lines := wrapText(text, maxChars)
+
+	// translucent background for readability
+	boxHeight := lineHeight*len(lines) + annotationPadding*2
+	box := image.Rect(bounds.Min.X, bounds.Max.Y-boxHeight, bounds.Max.X, bounds.Max.Y)
+	draw.Draw(img, box, image.NewUniform(color.RGBA{0, 0, 0, 160}), image.Point{}, draw.Over)
+
+	drawer := &font.Drawer{
+		Dst:  img,
+		Src:  image.White,
+		Face: face,
+	}
+	for i, line := range lines {
+		drawer.Dot = fixed.P(
+			box.Min.X+annotationPadding,
+			box.Min.Y+annotationPadding+lineHeight*i+face.Ascent,
+		)
+		drawer.DrawString(line)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := jpeg.Encode(buf, img, &jpeg.Options{Quality: annotationJpegQuality}); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
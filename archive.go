@@ -0,0 +1,90 @@
+// archiving outputs of executions
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	archiveTimeFormat = "20060102-150405.000"
+)
+
+// characters not allowed in names of archived files
+var unsafeFilenameChars = regexp.MustCompile(`[^A-Za-z0-9_\-]+`)
+
+// lock for writing to and pruning the archive directory
+var archiveLock sync.Mutex
+
+// check if outputs of given mime type should be archived (plain texts are not)
+func shouldArchive(mime string) bool {
+	return len(archiveDir) > 0 && !strings.HasPrefix(mime, "text/")
+}
+
+// name of the archived file for given output, eg. "20240102-150405.000_someone_detect_face.jpg"
+func archiveFilename(at time.Time, userID, script, mime string) string {
+	return fmt.Sprintf("%s_%s_%s%s",
+		at.Format(archiveTimeFormat),
+		unsafeFilenameChars.ReplaceAllString(userID, "_"),
+		unsafeFilenameChars.ReplaceAllString(filepath.Base(script), "_"),
+		filepath.Ext(documentFilename(mime)))
+}
+
+// write given output to the archive directory, and remove old ones
+func archiveOutput(request ExecuteRequest, mime string, output []byte) {
+	if !shouldArchive(mime) || len(output) <= 0 {
+		return
+	}
+
+	archiveLock.Lock()
+	defer archiveLock.Unlock()
+
+	path := filepath.Join(archiveDir, archiveFilename(time.Now(), request.UserID, valueOrDefault(request.ScriptName, request.ScriptPath), mime))
+	if err := ioutil.WriteFile(path, output, 0600); err != nil {
+		log.Printf("*** Failed to archive output: %s", err)
+		return
+	}
+
+	pruneArchive(time.Now())
+}
+
+// remove archived files exceeding the max number of files, or older than the max age
+//
+// (should be called while holding the lock)
+func pruneArchive(now time.Time) {
+	infos, err := ioutil.ReadDir(archiveDir)
+	if err != nil {
+		log.Printf("*** Failed to read archive directory: %s", err)
+		return
+	}
+
+	files := []os.FileInfo{}
+	for _, info := range infos {
+		if info.Mode().IsRegular() {
+			files = append(files, info)
+		}
+	}
+
+	// newest first
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime().After(files[j].ModTime())
+	})
+
+	maxAge := time.Duration(archiveMaxAgeDays) * 24 * time.Hour
+	for i, file := range files {
+		if (archiveMaxFiles > 0 && i >= archiveMaxFiles) || (maxAge > 0 && now.Sub(file.ModTime()) > maxAge) {
+			if err := os.Remove(filepath.Join(archiveDir, file.Name())); err != nil {
+				log.Printf("*** Failed to remove archived file: %s", err)
+			}
+		}
+	}
+}
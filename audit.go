@@ -0,0 +1,51 @@
+// audit log of executions
+
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// AuditEntry struct for an entry of the audit log
+type AuditEntry struct {
+	Time   time.Time `json:"time"`
+	UserID string    `json:"user_id"`
+	Script string    `json:"script"`
+	Args   []string  `json:"args,omitempty"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+// for appending entries to the audit log file
+var auditLock sync.Mutex
+
+// append an entry to the audit log file (or the log when no file is configured)
+func audit(entry AuditEntry) {
+	bytes, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("*** Failed to marshal audit entry: %s", err)
+		return
+	}
+
+	if len(auditLogPath) <= 0 {
+		log.Printf("Audit: %s", string(bytes))
+		return
+	}
+
+	auditLock.Lock()
+	defer auditLock.Unlock()
+
+	file, err := os.OpenFile(auditLogPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+	if err != nil {
+		log.Printf("*** Failed to open audit log: %s", err)
+		return
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(bytes, '\n')); err != nil {
+		log.Printf("*** Failed to write audit log: %s", err)
+	}
+}
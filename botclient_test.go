@@ -0,0 +1,147 @@
+// fake bot client for tests, which records calls instead of calling the bot API
+
+package main
+
+import (
+	"sync"
+	"testing"
+
+	bot "github.com/meinside/telegram-bot-go"
+)
+
+// FakeCall struct for a call recorded by FakeBotClient
+type FakeCall struct {
+	Method  string
+	ChatID  bot.ChatID
+	Text    string
+	File    bot.InputFile
+	Options map[string]interface{}
+}
+
+// FakeBotClient struct for recording calls to the bot API
+//
+// all calls succeed, unless Fail returns a description of the failure
+type FakeBotClient struct {
+	Calls []FakeCall
+	Fail  func(call FakeCall) *string
+
+	sync.Mutex
+}
+
+// record given call, and return its response
+func (c *FakeBotClient) record(call FakeCall) bot.APIResponseBase {
+	c.Lock()
+	defer c.Unlock()
+
+	c.Calls = append(c.Calls, call)
+
+	if c.Fail != nil {
+		if description := c.Fail(call); description != nil {
+			return bot.APIResponseBase{Ok: false, Description: description}
+		}
+	}
+	return bot.APIResponseBase{Ok: true}
+}
+
+// recorded calls of given method
+func (c *FakeBotClient) callsOf(method string) []FakeCall {
+	c.Lock()
+	defer c.Unlock()
+
+	calls := []FakeCall{}
+	for _, call := range c.Calls {
+		if call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+func (c *FakeBotClient) message(call FakeCall) bot.APIResponseMessage {
+	return bot.APIResponseMessage{APIResponseBase: c.record(call), Result: &bot.Message{}}
+}
+
+func (c *FakeBotClient) SendMessage(chatID bot.ChatID, text string, options bot.OptionsSendMessage) bot.APIResponseMessage {
+	return c.message(FakeCall{Method: "SendMessage", ChatID: chatID, Text: text, Options: options})
+}
+
+func (c *FakeBotClient) SendPhoto(chatID bot.ChatID, photo bot.InputFile, options bot.OptionsSendPhoto) bot.APIResponseMessage {
+	return c.message(FakeCall{Method: "SendPhoto", ChatID: chatID, File: photo, Options: options})
+}
+
+func (c *FakeBotClient) SendVideo(chatID bot.ChatID, video bot.InputFile, options bot.OptionsSendVideo) bot.APIResponseMessage {
+	return c.message(FakeCall{Method: "SendVideo", ChatID: chatID, File: video, Options: options})
+}
+
+func (c *FakeBotClient) SendAnimation(chatID bot.ChatID, animation bot.InputFile, options bot.OptionsSendAnimation) bot.APIResponseMessage {
+	return c.message(FakeCall{Method: "SendAnimation", ChatID: chatID, File: animation, Options: options})
+}
+
+func (c *FakeBotClient) SendDocument(chatID bot.ChatID, document bot.InputFile, options bot.OptionsSendDocument) bot.APIResponseMessage {
+	return c.message(FakeCall{Method: "SendDocument", ChatID: chatID, File: document, Options: options})
+}
+
+func (c *FakeBotClient) SendMediaGroup(chatID bot.ChatID, media []bot.InputMedia, options bot.OptionsSendMediaGroup) bot.APIResponseMessages {
+	return bot.APIResponseMessages{APIResponseBase: c.record(FakeCall{Method: "SendMediaGroup", ChatID: chatID, Options: options})}
+}
+
+func (c *FakeBotClient) SendChatAction(chatID bot.ChatID, action bot.ChatAction) bot.APIResponseBool {
+	return bot.APIResponseBool{APIResponseBase: c.record(FakeCall{Method: "SendChatAction", ChatID: chatID, Text: string(action)}), Result: true}
+}
+
+func (c *FakeBotClient) EditMessageText(text string, options bot.OptionsEditMessageText) bot.APIResponseMessageOrBool {
+	return bot.APIResponseMessageOrBool{APIResponseBase: c.record(FakeCall{Method: "EditMessageText", Text: text, Options: options})}
+}
+
+func (c *FakeBotClient) DeleteMessage(chatID bot.ChatID, messageID int) bot.APIResponseBool {
+	return bot.APIResponseBool{APIResponseBase: c.record(FakeCall{Method: "DeleteMessage", ChatID: chatID}), Result: true}
+}
+
+func (c *FakeBotClient) GetFile(fileID string) bot.APIResponseFile {
+	return bot.APIResponseFile{APIResponseBase: c.record(FakeCall{Method: "GetFile", Text: fileID}), Result: &bot.File{FileID: fileID}}
+}
+
+func (c *FakeBotClient) GetMe() bot.APIResponseUser {
+	return bot.APIResponseUser{APIResponseBase: c.record(FakeCall{Method: "GetMe"}), Result: &bot.User{}}
+}
+
+func (c *FakeBotClient) GetFileURL(file bot.File) string {
+	return "https://example.com/" + file.FileID
+}
+
+func (c *FakeBotClient) AnswerCallbackQuery(callbackQueryID string, options bot.OptionsAnswerCallbackQuery) bot.APIResponseBool {
+	return bot.APIResponseBool{APIResponseBase: c.record(FakeCall{Method: "AnswerCallbackQuery", Text: callbackQueryID, Options: options}), Result: true}
+}
+
+func (c *FakeBotClient) SetMessageReaction(chatID bot.ChatID, messageID int, emoji string) bot.APIResponseBool {
+	return bot.APIResponseBool{APIResponseBase: c.record(FakeCall{Method: "SetMessageReaction", ChatID: chatID, Text: emoji}), Result: true}
+}
+
+func TestRetryingClientResendsUnparsableTextWithoutFormatting(t *testing.T) {
+	fake := &FakeBotClient{
+		Fail: func(call FakeCall) *string {
+			if _, formatted := call.Options["parse_mode"]; formatted {
+				description := "Bad Request: can't parse entities: unclosed tag"
+				return &description
+			}
+			return nil
+		},
+	}
+	client := newRetryingClient(fake)
+
+	sent := client.SendMessage(int64(1), "<b>broken", bot.OptionsSendMessage{"parse_mode": bot.ParseModeHTML})
+	if !sent.Ok {
+		t.Fatalf("expected the message to be sent without formatting, got: %s", *sent.Description)
+	}
+
+	calls := fake.callsOf("SendMessage")
+	if len(calls) != 2 {
+		t.Fatalf("expected 2 calls of SendMessage, got %d", len(calls))
+	}
+	if _, formatted := calls[1].Options["parse_mode"]; formatted {
+		t.Errorf("expected the second call without parse_mode, got options: %v", calls[1].Options)
+	}
+	if calls[1].Text != "<b>broken" {
+		t.Errorf("expected the same text to be resent, got: %q", calls[1].Text)
+	}
+}
@@ -0,0 +1,164 @@
+// running other bots (with their own config files) along with this one
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	childBotEnv          = "TELEGRAM_BOT_OPENCV_CHILD" // set for processes of other bots, for not starting bots recursively
+	childBotRestartDelay = 5 * time.Second             // delay before restarting a bot which exited unexpectedly
+)
+
+// ChildBots struct for processes of other bots
+type ChildBots struct {
+	processes map[string]*os.Process // config path => running process
+	waits     sync.WaitGroup
+	stopping  bool
+	sync.Mutex
+}
+
+// processes of other bots
+var childBots = ChildBots{
+	processes: map[string]*os.Process{},
+}
+
+// default path of a file for persisting states (eg. sessions), next to given config file
+//
+// (prefixed with the config file's name unless it is the default one, eg. office.sessions.json for office.json,
+// so that bots with config files in the same directory do not share it)
+func defaultStatePath(configFile, filename string) string {
+	name := strings.TrimSuffix(filepath.Base(configFile), filepath.Ext(configFile))
+	if name != strings.TrimSuffix(configFilename, filepath.Ext(configFilename)) {
+		filename = name + "." + filename
+	}
+	return filepath.Join(filepath.Dir(configFile), filename)
+}
+
+// check that this bot and the bots of given config files do not share files for persisting states
+func checkStateFiles(configPaths []string) error {
+	owners := map[string]string{} // absolute path of a file => config file using it
+
+	use := func(configFile, path string) error {
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+		if owner, exists := owners[path]; exists {
+			return fmt.Errorf("bots with config files %s and %s share the same file: %s", owner, configFile, path)
+		}
+		owners[path] = configFile
+		return nil
+	}
+
+	self := configFilePath()
+	for _, path := range []string{sessionsPath, offsetPath, historyPath} {
+		if err := use(self, path); err != nil {
+			return err
+		}
+	}
+	for _, configFile := range configPaths {
+		config, err := loadConfig(configFile)
+		if err != nil {
+			return fmt.Errorf("failed to read config of bot %s: %s", configFile, err)
+		}
+
+		for _, path := range []string{
+			valueOrDefault(config.SessionsPath, defaultSessionsPath(configFile)),
+			valueOrDefault(config.OffsetPath, defaultOffsetPath(configFile)),
+			valueOrDefault(config.HistoryPath, defaultHistoryPath(configFile)),
+		} {
+			if err := use(configFile, path); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// check if this process is a bot started by another one
+func isChildBot() bool {
+	return len(os.Getenv(childBotEnv)) > 0
+}
+
+// start a process for each of given config files, restarting them when they exit unexpectedly
+//
+// (each bot has its own token, allowed ids, scripts, sessions, queue, and camera locks)
+func startChildBots(configPaths []string) {
+	executable, err := os.Executable()
+	if err != nil {
+		log.Printf("*** Failed to start other bots: %s", err)
+		return
+	}
+
+	for _, configPath := range configPaths {
+		childBots.waits.Add(1)
+		go superviseChildBot(executable, configPath)
+	}
+}
+
+// run a bot with given config file until stopped
+func superviseChildBot(executable, configPath string) {
+	defer childBots.waits.Done()
+
+	for {
+		cmd := exec.Command(executable, "-config", configPath)
+		cmd.Env = append(os.Environ(), childBotEnv+"=1")
+		cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+
+		childBots.Lock()
+		if childBots.stopping {
+			childBots.Unlock()
+			return
+		}
+		err := cmd.Start()
+		if err == nil {
+			childBots.processes[configPath] = cmd.Process
+		}
+		childBots.Unlock()
+
+		if err == nil {
+			log.Printf("Started bot with config: %s (pid: %d)", configPath, cmd.Process.Pid)
+
+			err = cmd.Wait()
+		}
+
+		childBots.Lock()
+		delete(childBots.processes, configPath)
+		stopping := childBots.stopping
+		childBots.Unlock()
+
+		if stopping {
+			return
+		}
+
+		log.Printf("*** Bot with config %s exited (%v), restarting in %s", configPath, err, childBotRestartDelay)
+		time.Sleep(childBotRestartDelay)
+	}
+}
+
+// pass given signal to the processes of other bots, and stop restarting them
+func signalChildBots(sig os.Signal) {
+	childBots.Lock()
+	defer childBots.Unlock()
+
+	childBots.stopping = true
+	for configPath, process := range childBots.processes {
+		if err := process.Signal(sig); err != nil {
+			log.Printf("*** Failed to stop bot with config %s: %s", configPath, err)
+		}
+	}
+}
+
+// wait for the processes of other bots to exit
+func waitChildBots() {
+	childBots.waits.Wait()
+}
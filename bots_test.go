@@ -0,0 +1,90 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDefaultStatePath(t *testing.T) {
+	tests := []struct {
+		configFile string
+		path       string
+	}{
+		{"/home/pi/config.json", "/home/pi/sessions.json"},
+		{"/home/pi/config.yaml", "/home/pi/sessions.json"},
+		{"/home/pi/office.json", "/home/pi/office.sessions.json"},
+		{"/home/pi/office.toml", "/home/pi/office.sessions.json"},
+	}
+
+	for _, test := range tests {
+		if path := defaultStatePath(test.configFile, defaultSessionsFilename); path != test.path {
+			t.Errorf("defaultStatePath(%q) = %q, want %q", test.configFile, path, test.path)
+		}
+	}
+}
+
+// write config files of this bot and other bots to a temporary directory, and set paths of this bot's files
+func withBotConfigs(t *testing.T, configs map[string]string) (dir string) {
+	savedConfig := *configPath
+	savedSessions, savedOffset, savedHistory := sessionsPath, offsetPath, historyPath
+	t.Cleanup(func() {
+		*configPath = savedConfig
+		sessionsPath, offsetPath, historyPath = savedSessions, savedOffset, savedHistory
+	})
+
+	dir = t.TempDir()
+	for filename, content := range configs {
+		if err := ioutil.WriteFile(filepath.Join(dir, filename), []byte(content), 0600); err != nil {
+			t.Fatalf("failed to write config: %s", err)
+		}
+	}
+
+	*configPath = filepath.Join(dir, configFilename)
+	sessionsPath = defaultSessionsPath(*configPath)
+	offsetPath = defaultOffsetPath(*configPath)
+	historyPath = defaultHistoryPath(*configPath)
+
+	return dir
+}
+
+func TestCheckStateFiles(t *testing.T) {
+	dir := withBotConfigs(t, map[string]string{
+		configFilename: `{}`,
+		"office.json":  `{}`,
+		"garage.json":  `{}`,
+	})
+
+	// (given as an absolute path, as relative ones are resolved from the working directory)
+	sharing := filepath.Join(dir, "sharing.json")
+	if err := ioutil.WriteFile(sharing, []byte(`{"sessions_path": "`+filepath.Join(dir, "office.sessions.json")+`"}`), 0600); err != nil {
+		t.Fatalf("failed to write config: %s", err)
+	}
+
+	tests := []struct {
+		name    string
+		configs []string
+		shared  bool
+	}{
+		{"configs in the same directory", []string{"office.json", "garage.json"}, false},
+		{"explicit path shared with another bot", []string{"office.json", "sharing.json"}, true},
+		{"same config listed twice", []string{"office.json", "office.json"}, true},
+		{"this bot's own config", []string{configFilename}, true},
+	}
+
+	for _, test := range tests {
+		paths := []string{}
+		for _, config := range test.configs {
+			paths = append(paths, filepath.Join(dir, config))
+		}
+
+		err := checkStateFiles(paths)
+		if shared := err != nil; shared != test.shared {
+			t.Errorf("%s: expected shared: %t, got: %v", test.name, test.shared, err)
+		}
+		if err != nil && !strings.Contains(err.Error(), "share the same file") {
+			t.Errorf("%s: unexpected error: %s", test.name, err)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+// captions built from metadata printed by scripts
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"strings"
+	"text/template"
+)
+
+const (
+	metaMarker    = "#META:"         // prefix of a header line of output, followed by json
+	captionMarker = "CAPTION:"       // prefix of a header line of output, followed by a caption
+	sendMarker    = "TELEGRAM-SEND:" // prefix of a header line of output, followed by the way of sending it
+
+	maxCaptionLength = 1024 // max length of captions (in characters)
+)
+
+// ways of sending outputs, given with the header line of sendMarker
+const (
+	sendAsPhoto     = "photo"
+	sendAsVideo     = "video"
+	sendAsAnimation = "animation"
+	sendAsDocument  = "document"
+	sendAsText      = "text"
+)
+
+// check if given value is a known way of sending outputs
+func isSendAs(value string) bool {
+	switch value {
+	case sendAsPhoto, sendAsVideo, sendAsAnimation, sendAsDocument, sendAsText:
+		return true
+	}
+	return false
+}
+
+// split the header line with given marker (if any) from the output of a script
+func splitHeader(output []byte, marker string) (value []byte, rest []byte, found bool) {
+	if !bytes.HasPrefix(output, []byte(marker)) {
+		return nil, output, false
+	}
+
+	line := output
+	if i := bytes.IndexByte(output, '\n'); i >= 0 {
+		line, rest = output[:i], output[i+1:]
+	}
+
+	return bytes.TrimSpace(line[len(marker):]), rest, true
+}
+
+// split header lines (if any) from the output of a script, in any order
+//
+// eg. "#META: {\"detected\": \"2 cats, 1 dog\"}\nCAPTION: 3 faces detected.\nTELEGRAM-SEND: document\n<image bytes>"
+func splitHeaders(output []byte) (meta []byte, caption, sendAs string, rest []byte) {
+	rest = output
+	for {
+		if value, remaining, found := splitHeader(rest, metaMarker); found {
+			meta, rest = value, remaining
+		} else if value, remaining, found := splitHeader(rest, captionMarker); found {
+			caption, rest = string(value), remaining
+		} else if value, remaining, found := splitHeader(rest, sendMarker); found {
+			sendAs, rest = strings.ToLower(string(value)), remaining
+		} else {
+			break
+		}
+	}
+
+	return meta, caption, sendAs, rest
+}
+
+// truncate given caption to the max length of captions
+func truncateCaption(caption string) string {
+	if runes := []rune(caption); len(runes) > maxCaptionLength {
+		return string(runes[:maxCaptionLength-1]) + "…"
+	}
+	return caption
+}
+
+// parse given caption template
+func parseCaptionTemplate(text string) (*template.Template, error) {
+	return template.New("caption").Option("missingkey=error").Parse(text)
+}
+
+// render a caption with given template and json metadata
+//
+// (returns an empty string when it fails)
+func renderCaption(text string, meta []byte) string {
+	if len(text) <= 0 || len(meta) <= 0 {
+		return ""
+	}
+
+	var values interface{}
+	if err := json.Unmarshal(meta, &values); err != nil {
+		log.Printf("*** Failed to parse metadata: %s", err)
+		return ""
+	}
+
+	tmpl, err := parseCaptionTemplate(text)
+	if err != nil {
+		log.Printf("*** Failed to parse caption template: %s", err)
+		return ""
+	}
+
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, values); err != nil {
+		log.Printf("*** Failed to render caption: %s", err)
+		return ""
+	}
+
+	return strings.TrimSpace(buf.String())
+}
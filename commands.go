@@ -0,0 +1,840 @@
+// command handlers
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	bot "github.com/meinside/telegram-bot-go"
+)
+
+// CommandContext struct for a command being handled
+type CommandContext struct {
+	Bot     BotClient
+	Message *bot.Message
+	UserID  string
+	Args    string  // text after the command
+	Session Session // user's session (changes should be written back to the pool)
+
+	InputFileID string // id of an uploaded photo (for executing with it)
+
+	Reply    string                 // message to reply
+	Options  map[string]interface{} // options for the reply
+	Request  *ExecuteRequest        // request to be queued
+	Deferred func() bool            // function to be run after releasing the pool lock
+}
+
+// CommandHandler is a function for handling a command
+//
+// (called while holding pool's lock)
+type CommandHandler func(c *CommandContext)
+
+const (
+	maxSuggestionDistance = 2 // max edit distance of suggested commands for unknown ones
+)
+
+// registered command handlers
+var commandHandlers = map[string]CommandHandler{}
+
+// registered commands and their descriptions, in order of registration
+var commandDescriptions = [][2]string{}
+
+// register a handler (and one-line description) for given command
+func registerCommandHandler(command, description string, handler CommandHandler) {
+	if _, exists := commandHandlers[command]; !exists {
+		commandDescriptions = append(commandDescriptions, [2]string{command, description})
+	}
+	commandHandlers[command] = handler
+}
+
+// register built-in command handlers
+func init() {
+	registerCommandHandler(commandStart, "show the default message", handleStart)
+	registerCommandHandler(commandHelp, "show this help", handleHelp)
+	registerCommandHandler(commandExecute, "execute a script: /execute [script] [args...]", handleExecute)
+	registerCommandHandler(commandScripts, "list available scripts", handleScripts)
+	registerCommandHandler(commandCancel, "cancel the pending request", handleCancel)
+	registerCommandHandler(commandStop, "stop the running execution: /stop [all]", handleStop)
+	registerCommandHandler(commandCancelQueue, "cancel your queued requests (running ones are not stopped)", handleCancelQueue)
+	registerCommandHandler(commandAnnotate, "execute the default script with annotation: /annotate <text>", handleAnnotate)
+	registerCommandHandler(commandClip, "record a video clip: /clip <seconds>", handleClip)
+	registerCommandHandler(commandLast, "resend the last output without executing again", handleLast)
+	registerCommandHandler(commandFlushCache, "remove cached results (admin only)", adminOnly(handleFlushCache))
+	registerCommandHandler(commandHistory, "show your recent executions: /history [count] (admins: /history all [count])", handleHistory)
+	registerCommandHandler(commandFormat, "set formatting of text replies: /format markdown|html|none", handleFormat)
+	registerCommandHandler(commandSchedules, "list scheduled executions", handleSchedules)
+	registerCommandHandler(commandWhoAmI, "show your id, username, and whether you are allowed", handleWhoAmI)
+	registerCommandHandler(commandSnap, "run the default script right away, without queueing", handleSnap)
+	registerCommandHandler(commandPipeline, "run scripts in sequence, piping outputs: /pipeline capture|detect|annotate", handlePipeline)
+	registerCommandHandler(commandShowCode, "show the code of a script: /showcode [script]", handleShowCode)
+	registerCommandHandler(commandConfig, "show the current config (admin only)", adminOnly(handleConfig))
+	registerCommandHandler(commandCamReset, "reset the camera (admin only)", adminOnly(handleCamReset))
+	registerCommandHandler(commandInterval, "change the monitor interval: /interval <seconds> (admin only)", adminOnly(handleInterval))
+	registerCommandHandler(commandMaintenance, "reject all executions: /maintenance on|off (admin only)", adminOnly(handleMaintenance))
+	registerCommandHandler(commandDisk, "show free disk space", handleDisk)
+	registerCommandHandler(commandStatus, "show the status of the bot", handleStatus)
+	registerCommandHandler(commandPing, "measure latency to Telegram", handlePing)
+	registerCommandHandler(commandBusy, "show whether the camera is busy", handleBusy)
+	registerCommandHandler(commandVersion, "show the version of this bot", handleVersion)
+	registerCommandHandler(commandPreview, "preview how a script will be run: /preview <script> (admin only)", adminOnly(handlePreview))
+	registerCommandHandler(commandReload, "reload the config file (admin only)", adminOnly(handleReload))
+}
+
+// split given text into a command word and its arguments
+//
+// the command word is matched exactly by the callers, so "/executefoo" is not "/execute";
+// bot's username in the command will be stripped (eg. /execute@some_bot => /execute),
+// but commands for other bots are left as they are (eg. /execute@other_bot)
+//
+// when the command prefix is configured, commands without it are not accepted (returns an empty command)
+func parseCommand(txt string) (command, args string) {
+	txt = strings.TrimSpace(txt)
+
+	if i := strings.IndexFunc(txt, unicode.IsSpace); i >= 0 {
+		command, args = txt[:i], strings.TrimSpace(txt[i:])
+	} else {
+		command = txt
+	}
+	if !strings.HasPrefix(command, "/") {
+		return command, args
+	}
+	if i := strings.Index(command, "@"); i >= 0 && isBotUsername(command[i+1:]) {
+		command = command[:i]
+	}
+
+	if unprefixed, ok := unprefixedCommand(command); ok {
+		return unprefixed, args
+	}
+	return "", txt
+}
+
+// pattern of valid command prefixes (without the trailing separator)
+var commandPrefixPattern = regexp.MustCompile(`^[a-z0-9]+(_[a-z0-9]+)*$`)
+
+// separator between the command prefix and commands, eg. "cv" + "_" + "execute"
+const commandPrefixSeparator = "_"
+
+// given command with the command prefix, eg. "/execute" => "/cv_execute"
+func prefixedCommand(command string) string {
+	if len(commandPrefix) <= 0 || !strings.HasPrefix(command, "/") {
+		return command
+	}
+	return "/" + commandPrefix + commandPrefixSeparator + strings.TrimPrefix(command, "/")
+}
+
+// given command without the command prefix, eg. "/cv_execute" => "/execute"
+//
+// returns false when the command prefix is configured and given command does not have it
+// (except /start, which is sent by Telegram clients without the prefix when a chat is opened)
+func unprefixedCommand(command string) (string, bool) {
+	if len(commandPrefix) <= 0 {
+		return command, true
+	}
+
+	name := strings.TrimPrefix(command, "/"+commandPrefix+commandPrefixSeparator)
+	if name == command || len(name) <= 0 {
+		return command, command == commandStart
+	}
+	return "/" + name, true
+}
+
+// check if given text starts with a command with the command prefix
+func hasPrefixedCommand(txt string) bool {
+	return len(commandPrefix) > 0 && strings.HasPrefix(strings.TrimSpace(txt), "/"+commandPrefix+commandPrefixSeparator)
+}
+
+// wrap given handler so that only admins can run it
+func adminOnly(handler CommandHandler) CommandHandler {
+	return func(c *CommandContext) {
+		if isAdminID(c.UserID) {
+			handler(c)
+		} else {
+			c.Reply = messageAdminOnly
+		}
+	}
+}
+
+// check cooldown and pending count, and set an execute request of given script
+//
+// (when the script requires a confirmation or a reason, the user will be asked for it first)
+func (c *CommandContext) execute(scriptName, scriptPath string, args []string) {
+	if !canRunScript(c.UserID, scriptName) {
+		log.Printf("*** Script not permitted for %s: %s", c.UserID, scriptName)
+
+		c.Reply = messageScriptNotPermitted
+		return
+	}
+
+	request := ExecuteRequest{
+		UserID:         c.UserID,
+		ChatID:         c.Message.Chat.ID,
+		MessageID:      c.Message.MessageID,
+		ScriptName:     scriptName,
+		ScriptPath:     scriptPath,
+		Args:           args,
+		MessageOptions: c.Options,
+		InputFileID:    c.InputFileID,
+	}
+
+	// remember the selected script
+	if len(scriptName) > 0 {
+		c.Session.LastScript, c.Session.LastArgs = scriptName, args
+	}
+
+	if scripts[scriptName].RequiresConfirmation {
+		pool.Sessions[c.UserID] = c.Session
+
+		var keyboard bot.InlineKeyboardMarkup
+		c.Reply, keyboard = awaitConfirmation(request)
+		c.Options["reply_markup"] = keyboard
+	} else if scripts[scriptName].RequireReason {
+		c.Reply = awaitReason(c.UserID, c.Session, request)
+	} else if c.Reply = reserveExecution(c.UserID, c.Message.Chat.ID, c.Session); len(c.Reply) <= 0 {
+		c.Request = &request
+	}
+}
+
+// start
+func handleStart(c *CommandContext) {
+	c.Reply = welcomeOf(c.UserID)
+}
+
+// show supported commands and non-sensitive configs
+func handleHelp(c *CommandContext) {
+	c.Reply = helpMessage()
+}
+
+// execute
+func handleExecute(c *CommandContext) {
+	// script name (optional) and arguments for the script
+	name, args := "", strings.Fields(c.Args)
+	if len(args) > 0 {
+		if _, exists := scripts[args[0]]; exists {
+			name, args = args[0], args[1:]
+		}
+	}
+	if invalid := invalidArgs(args); len(invalid) > 0 {
+		c.Reply = fmt.Sprintf(messageInvalidArgs, strings.Join(invalid, " "))
+		return
+	}
+
+	if script, exists := scripts[name]; exists {
+		if !canRunScript(c.UserID, name) {
+			log.Printf("*** Script not permitted for %s: %s", c.UserID, name)
+
+			c.Reply = messageScriptNotPermitted
+		} else if len(script.Parameters) > 0 && len(args) <= 0 {
+			// collect values of the parameters with follow-up messages
+			c.Session.CurrentStatus = StatusCollectingParameters
+			c.Session.ConfiguringScript = name
+			c.Session.ConfiguringValues = nil
+			pool.Sessions[c.UserID] = c.Session
+
+			var keyboard bot.ReplyKeyboardMarkup
+			c.Reply, keyboard = parameterPrompt(name, script, 0)
+			c.Options["reply_markup"] = keyboard
+		} else if param, invalid := invalidParameterArg(script, args); len(script.Parameters) > 0 && invalid {
+			// (values given inline should also be the allowed ones)
+			c.Reply = messageInvalidValue
+			if param != nil {
+				c.Reply = appendLine(c.Reply, fmt.Sprintf(messageAllowedValuesFormat, param.Name, strings.Join(param.Values, ", ")))
+			}
+		} else {
+			// execute the named script
+			c.execute(name, script.Path, args)
+		}
+	} else if script, exists := scripts[c.Session.LastScript]; exists && len(args) <= 0 {
+		// re-run the last selected script
+		c.execute(c.Session.LastScript, script.Path, c.Session.LastArgs)
+	} else if len(scriptPath) > 0 && (len(args) > 0 || len(scripts) <= 0) {
+		// execute the default script with given arguments
+		c.execute("", scriptPath, args)
+	} else if len(scripts) > 0 {
+		// let the user choose one of the scripts (with the keyboard, or a following message)
+		c.Session.CurrentStatus = StatusAwaitingScriptChoice
+		pool.Sessions[c.UserID] = c.Session
+
+		var keyboard bot.InlineKeyboardMarkup
+		c.Reply, keyboard = scriptsKeyboard(c.UserID, 0)
+		c.Reply = appendLine(c.Reply, messageAskScriptChoice)
+		c.Options["reply_markup"] = keyboard
+	} else {
+		c.Reply = messageNoDefaultScript
+	}
+}
+
+// list available scripts
+func handleScripts(c *CommandContext) {
+	names := scriptNames(c.UserID)
+	if len(names) <= 0 {
+		c.Reply = messageNoScripts
+		return
+	}
+
+	if inlineKeyboardOnly {
+		var keyboard bot.InlineKeyboardMarkup
+		c.Reply, keyboard = scriptsKeyboard(c.UserID, 0)
+		c.Options["reply_markup"] = keyboard
+		return
+	}
+
+	keyboard := [][]bot.KeyboardButton{}
+	for _, name := range names {
+		keyboard = append(keyboard, []bot.KeyboardButton{{Text: prefixedCommand(commandExecute) + " " + name}})
+	}
+	keyboard = append(keyboard, keyboardOf(c.UserID)...)
+
+	c.Reply = fmt.Sprintf(messageScriptsFormat, strings.Join(names, "\n"))
+	c.Options["reply_markup"] = bot.ReplyKeyboardMarkup{
+		Keyboard:       keyboard,
+		ResizeKeyboard: true,
+	}
+}
+
+// cancel things in progress
+func handleCancel(c *CommandContext) {
+	if len(c.Session.ConfiguringScript) > 0 || c.Session.CurrentStatus == StatusAwaitingScriptChoice {
+		c.Session = clearConfiguring(c.UserID, c.Session)
+
+		c.Reply = messageCancelled
+	} else {
+		c.Reply = messageNothingToCancel
+	}
+}
+
+// handle a message with the value of the parameter being collected
+func handleParameterValue(c *CommandContext, value string) {
+	name := c.Session.ConfiguringScript
+	script, exists := scripts[name]
+	if !exists || len(c.Session.ConfiguringValues) >= len(script.Parameters) {
+		clearConfiguring(c.UserID, c.Session)
+
+		c.Reply = messageNotConfiguring
+		return
+	}
+
+	index := len(c.Session.ConfiguringValues)
+	if !script.Parameters[index].allows(value) {
+		var keyboard bot.ReplyKeyboardMarkup
+		c.Reply, keyboard = parameterPrompt(name, script, index)
+		c.Reply = messageInvalidValue + "\n" + c.Reply
+		c.Options["reply_markup"] = keyboard
+	} else if index+1 < len(script.Parameters) {
+		c.Session.ConfiguringValues = append(c.Session.ConfiguringValues, value)
+		pool.Sessions[c.UserID] = c.Session
+
+		var keyboard bot.ReplyKeyboardMarkup
+		c.Reply, keyboard = parameterPrompt(name, script, index+1)
+		c.Options["reply_markup"] = keyboard
+	} else {
+		values := append(c.Session.ConfiguringValues, value)
+		c.Session = clearConfiguring(c.UserID, c.Session)
+
+		c.execute(name, script.Path, parameterArgs(script, values))
+	}
+}
+
+// handle a message with the name of a script (and its arguments) to execute
+func handleScriptChoice(c *CommandContext, value string) {
+	args := strings.Fields(value)
+	if len(args) <= 0 {
+		c.Reply = messageAskScriptChoice
+		return
+	}
+
+	name, args := args[0], args[1:]
+	script, exists := scripts[name]
+	if !exists || !canRunScript(c.UserID, name) {
+		c.Reply = appendLine(messageNoSuchScript, messageAskScriptChoice)
+		return
+	}
+	if invalid := invalidArgs(args); len(invalid) > 0 {
+		c.Reply = appendLine(fmt.Sprintf(messageInvalidArgs, strings.Join(invalid, " ")), messageAskScriptChoice)
+		return
+	}
+
+	if len(script.Parameters) > 0 && len(args) <= 0 {
+		// collect values of the parameters with follow-up messages
+		c.Session.CurrentStatus = StatusCollectingParameters
+		c.Session.ConfiguringScript = name
+		c.Session.ConfiguringValues = nil
+		pool.Sessions[c.UserID] = c.Session
+
+		var keyboard bot.ReplyKeyboardMarkup
+		c.Reply, keyboard = parameterPrompt(name, script, 0)
+		c.Options["reply_markup"] = keyboard
+	} else if param, invalid := invalidParameterArg(script, args); len(script.Parameters) > 0 && invalid {
+		// (values given inline should also be the allowed ones)
+		c.Reply = messageInvalidValue
+		if param != nil {
+			c.Reply = appendLine(c.Reply, fmt.Sprintf(messageAllowedValuesFormat, param.Name, strings.Join(param.Values, ", ")))
+		}
+	} else if len(args) <= 0 {
+		// ask for the arguments with a follow-up message
+		c.Session.CurrentStatus = StatusAwaitingArgs
+		c.Session.ConfiguringScript = name
+		pool.Sessions[c.UserID] = c.Session
+
+		c.Reply = fmt.Sprintf(messageAskArgsFormat, name)
+	} else {
+		c.Session = clearConfiguring(c.UserID, c.Session)
+
+		c.execute(name, script.Path, args)
+	}
+}
+
+// handle a message with the arguments of the chosen script
+func handleArgsValue(c *CommandContext, value string) {
+	name := c.Session.ConfiguringScript
+	script, exists := scripts[name]
+	if !exists {
+		clearConfiguring(c.UserID, c.Session)
+
+		c.Reply = messageNotConfiguring
+		return
+	}
+
+	args := strings.Fields(value)
+	if value == argumentNone {
+		args = nil
+	} else if len(args) <= 0 {
+		c.Reply = fmt.Sprintf(messageAskArgsFormat, name)
+		return
+	}
+	if invalid := invalidArgs(args); len(invalid) > 0 {
+		c.Reply = appendLine(fmt.Sprintf(messageInvalidArgs, strings.Join(invalid, " ")), fmt.Sprintf(messageAskArgsFormat, name))
+		return
+	}
+
+	c.Session = clearConfiguring(c.UserID, c.Session)
+
+	c.execute(name, script.Path, args)
+}
+
+// stop the user's running execution
+//
+// (with "all", also cancel queued requests, and admins stop executions of everyone)
+func handleStop(c *CommandContext) {
+	if c.Args == argumentAll {
+		c.Session.CancelledBefore = time.Now()
+		pool.Sessions[c.UserID] = c.Session
+
+		c.Reply = appendLine(stopExecution(c.UserID, true), messageQueueCancelled)
+	} else {
+		c.Reply = stopExecution(c.UserID, false)
+	}
+}
+
+// cancel queued requests of the user
+func handleCancelQueue(c *CommandContext) {
+	queued := c.Session.PendingCount - numRunningOf(c.UserID)
+	if queued <= 0 {
+		c.Reply = messageNothingQueued
+		return
+	}
+
+	c.Session.CancelledBefore = time.Now()
+	pool.Sessions[c.UserID] = c.Session
+
+	c.Reply = fmt.Sprintf(messageQueueCancelledFormat, queued)
+}
+
+// execute and annotate
+func handleAnnotate(c *CommandContext) {
+	if len(c.Args) <= 0 {
+		c.Reply = messageAnnotateUsage
+	} else if len(scriptPath) <= 0 {
+		c.Reply = messageNoDefaultScript
+	} else if c.execute("", scriptPath, nil); c.Request != nil {
+		c.Request.Annotation = c.Args
+	}
+}
+
+// run the default script right away with a short timeout, bypassing the queue
+//
+// (fails immediately when the camera is busy)
+func handleSnap(c *CommandContext) {
+	if len(scriptPath) <= 0 {
+		c.Reply = messageNoDefaultScript
+		return
+	}
+	if !canRunScript(c.UserID, "") {
+		log.Printf("*** Script not permitted for %s: %s", c.UserID, scriptPath)
+
+		c.Reply = messageScriptNotPermitted
+		return
+	}
+	if c.Reply = reserveExecution(c.UserID, c.Message.Chat.ID, c.Session); len(c.Reply) > 0 {
+		return
+	}
+
+	request := ExecuteRequest{
+		UserID:         c.UserID,
+		ChatID:         c.Message.Chat.ID,
+		MessageID:      c.Message.MessageID,
+		ScriptPath:     scriptPath,
+		RequestedAt:    time.Now(),
+		Device:         defaultDevice,
+		MessageOptions: c.Options,
+		Immediate:      true,
+		TimeoutSeconds: snapTimeoutSeconds,
+	}
+	b := c.Bot
+	c.Deferred = func() bool {
+		return processExecuteRequest(b, request)
+	}
+}
+
+// run scripts in a pipeline
+func handlePipeline(c *CommandContext) {
+	if len(strings.TrimSpace(c.Args)) <= 0 {
+		c.Reply = messagePipelineUsage
+		return
+	}
+
+	stages, err := parsePipeline(c.Args)
+	if err != nil {
+		c.Reply = fmt.Sprintf(messageErrorFormat, err)
+		return
+	}
+	for _, name := range stages {
+		if !canRunScript(c.UserID, name) {
+			log.Printf("*** Script not permitted for %s in pipeline: %s", c.UserID, name)
+
+			c.Reply = messageScriptNotPermitted
+			return
+		}
+
+		// (these stages would run without asking)
+		if script := scripts[name]; script.RequireReason || script.RequiresConfirmation {
+			c.Reply = fmt.Sprintf(messageNotInPipeline, name)
+			return
+		}
+	}
+
+	if c.Reply = reserveExecution(c.UserID, c.Message.Chat.ID, c.Session); len(c.Reply) <= 0 {
+		c.Request = &ExecuteRequest{
+			UserID:         c.UserID,
+			ChatID:         c.Message.Chat.ID,
+			MessageID:      c.Message.MessageID,
+			ScriptName:     strings.Join(stages, pipelineSeparator),
+			MessageOptions: c.Options,
+			Pipeline:       stages,
+		}
+	}
+}
+
+// record a clip
+func handleClip(c *CommandContext) {
+	seconds, err := strconv.Atoi(c.Args)
+	if len(clipScriptPath) <= 0 {
+		c.Reply = messageNoClipScript
+	} else if err != nil || seconds <= 0 {
+		c.Reply = fmt.Sprintf(messageClipUsage, maxClipSeconds)
+	} else if seconds > maxClipSeconds {
+		c.Reply = fmt.Sprintf(messageClipTooLong, seconds, maxClipSeconds)
+	} else if c.execute("", clipScriptPath, []string{"--duration", strconv.Itoa(seconds)}); c.Request != nil {
+		c.Request.ClipSeconds = seconds
+	}
+}
+
+// show build info
+func handleVersion(c *CommandContext) {
+	c.Reply = versionMessage()
+}
+
+// measure the round-trip time of an API call, and the delay of the update
+//
+// (the delay includes time spent on this machine before handling it, and has a resolution of seconds)
+func handlePing(c *CommandContext) {
+	b, chatID, options := c.Bot, c.Message.Chat.ID, c.Options
+	delay := time.Since(time.Unix(int64(c.Message.Date), 0)).Truncate(time.Second)
+	c.Deferred = func() bool {
+		start := time.Now()
+		me := b.GetMe()
+		elapsed := time.Since(start).Milliseconds()
+
+		var message string
+		if me.Ok {
+			message = fmt.Sprintf(messagePingFormat, elapsed, delay)
+		} else {
+			message = fmt.Sprintf(messagePingFailedFormat, elapsed, *me.Description)
+		}
+
+		sent := b.SendMessage(chatID, message, options)
+		if !sent.Ok {
+			log.Printf("*** Failed to send message: %s", *sent.Description)
+		}
+		return sent.Ok
+	}
+}
+
+// resend the last output
+func handleLast(c *CommandContext) {
+	if output, exists := lastOutputs.get(c.UserID); exists {
+		b, chatID, options := c.Bot, c.Message.Chat.ID, c.Options
+		c.Deferred = func() bool {
+			return resendOutput(b, chatID, output, options)
+		}
+	} else {
+		c.Reply = messageNoLastOutput
+	}
+}
+
+// remove cached results, so that scripts will be run again
+func handleFlushCache(c *CommandContext) {
+	num := resultCache.flush()
+
+	log.Printf("Result cache flushed by %s", c.UserID)
+
+	c.Reply = fmt.Sprintf(messageCacheFlushedFormat, num)
+}
+
+// show the history of executions
+func handleHistory(c *CommandContext) {
+	args := strings.Fields(c.Args)
+
+	// admins can see everyone's history
+	userID := c.UserID
+	if len(args) > 0 && args[0] == argumentAll {
+		if !isAdminID(c.UserID) {
+			c.Reply = messageAdminOnly
+			return
+		}
+		userID, args = "", args[1:]
+	}
+
+	count := defaultHistoryCount
+	if len(args) > 0 {
+		if n, err := strconv.Atoi(args[0]); err == nil && n > 0 {
+			count = n
+		}
+		if count > maxHistoryCount {
+			count = maxHistoryCount
+		}
+	}
+
+	c.Reply = formatHistory(history.last(userID, count), len(userID) <= 0)
+}
+
+// set formatting of text replies
+func handleFormat(c *CommandContext) {
+	switch strings.ToLower(strings.TrimSpace(c.Args)) {
+	case formatMarkdown:
+		c.Session.ParseMode = bot.ParseModeMarkdown
+	case formatHTML:
+		c.Session.ParseMode = bot.ParseModeHTML
+	case formatNone:
+		c.Session.ParseMode = ""
+	default:
+		c.Reply = messageFormatUsage
+		return
+	}
+	pool.Sessions[c.UserID] = c.Session
+
+	c.Options = sessionMessageOptions(c.Session)
+	c.Reply = fmt.Sprintf(messageFormatFormat, strings.ToLower(strings.TrimSpace(c.Args)))
+}
+
+// list scheduled executions
+func handleSchedules(c *CommandContext) {
+	c.Reply = schedulesMessage()
+}
+
+// show the user's id
+//
+// (users who are not allowed are answered before reaching here)
+func handleWhoAmI(c *CommandContext) {
+	c.Reply = whoAmIMessage(c.Message.From, true)
+}
+
+// show code
+func handleShowCode(c *CommandContext) {
+	code, filename, err := readCode(c.Args)
+	if err != nil {
+		c.Reply = fmt.Sprintf(messageErrorFormat, err)
+	} else if utf8.Valid(code) && len(code) <= messageChunkBytes {
+		c.Reply = string(code)
+	} else {
+		// send large (or binary) code as a document
+		b, chatID, options := c.Bot, c.Message.Chat.ID, c.Options
+		c.Deferred = func() bool {
+			b.SendChatAction(chatID, bot.ChatActionUploadDocument)
+
+			sent := sendDocumentWithFilename(b, chatID, code, filename, options)
+			if !sent.Ok {
+				log.Printf("*** Failed to send code: %s", *sent.Description)
+			}
+			return sent.Ok
+		}
+	}
+}
+
+// show config
+func handleConfig(c *CommandContext) {
+	c.Reply = sanitizedConfig()
+}
+
+// reset camera
+func handleCamReset(c *CommandContext) {
+	if len(strings.Fields(cameraResetCommand)) <= 0 {
+		c.Reply = messageNoCameraReset
+	} else {
+		b, chatID := c.Bot, c.Message.Chat.ID
+		c.Deferred = func() bool {
+			return processCameraReset(b, chatID, defaultMessageOptions())
+		}
+	}
+}
+
+// reload config
+func handleReload(c *CommandContext) {
+	b, chatID, options := c.Bot, c.Message.Chat.ID, c.Options
+	c.Deferred = func() bool {
+		return processReload(b, chatID, options)
+	}
+}
+
+// change monitor interval
+func handleInterval(c *CommandContext) {
+	args := strings.Fields(c.Args)
+	if len(args) <= 0 {
+		c.Reply = fmt.Sprintf(messageIntervalFormat, getMonitorInterval())
+	} else if interval, err := strconv.Atoi(args[0]); err == nil && interval >= minMonitorIntervalSeconds && interval <= maxMonitorIntervalSeconds {
+		setMonitorInterval(interval)
+
+		log.Printf("Monitor interval changed to %d second(s) by %s", interval, c.UserID)
+
+		c.Reply = fmt.Sprintf(messageIntervalFormat, interval)
+	} else {
+		c.Reply = fmt.Sprintf(messageInvalidInterval, minMonitorIntervalSeconds, maxMonitorIntervalSeconds)
+	}
+}
+
+// show or change maintenance mode
+func handleMaintenance(c *CommandContext) {
+	switch strings.ToLower(strings.TrimSpace(c.Args)) {
+	case "":
+	case "on":
+		setMaintenance(true, c.UserID)
+	case "off":
+		setMaintenance(false, c.UserID)
+	default:
+		c.Reply = messageMaintenanceUsage
+		return
+	}
+
+	state := "off"
+	if isInMaintenance() {
+		state = "on"
+	}
+	c.Reply = fmt.Sprintf(messageMaintenanceFormat, state)
+}
+
+// show status of the bot
+func handleStatus(c *CommandContext) {
+	c.Reply = statusMessage()
+}
+
+// show whether devices are busy (and who is using them, to admins)
+func handleBusy(c *CommandContext) {
+	c.Reply = describeDevices(isAdminID(c.UserID))
+}
+
+// show free disk space
+func handleDisk(c *CommandContext) {
+	if free, err := freeDiskSpace(diskCheckPath); err == nil {
+		c.Reply = fmt.Sprintf(messageDiskFormat, formatMB(free), diskCheckPath)
+	} else {
+		c.Reply = fmt.Sprintf(messageErrorFormat, err)
+	}
+}
+
+// show how a script would be executed, without executing it
+func handlePreview(c *CommandContext) {
+	if script, exists := scripts[c.Args]; exists {
+		c.Reply = previewScript(c.Args, script)
+	} else if len(c.Args) <= 0 && len(scriptPath) > 0 {
+		c.Reply = previewScript("(default)", Script{Path: scriptPath})
+	} else {
+		c.Reply = messageNoSuchScript
+	}
+}
+
+// help message with supported commands and non-sensitive configs
+//
+// (api token and allowed/admin ids are not included)
+func helpMessage() string {
+	lines := []string{}
+	for _, d := range commandDescriptions {
+		lines = append(lines, fmt.Sprintf("%s - %s", prefixedCommand(d[0]), d[1]))
+	}
+
+	return fmt.Sprintf(messageHelpFormat, strings.Join(lines, "\n"), getMonitorInterval(), len(scripts), isVerbose)
+}
+
+// edit distance between given strings
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	prev := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr := make([]int, len(rb)+1)
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = minInt(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev = curr
+	}
+	return prev[len(rb)]
+}
+
+// the smallest of given ints
+func minInt(first int, rest ...int) int {
+	result := first
+	for _, v := range rest {
+		if v < result {
+			result = v
+		}
+	}
+	return result
+}
+
+// first word of given text (eg. a command without the command prefix)
+func firstWord(txt string) string {
+	if fields := strings.Fields(txt); len(fields) > 0 {
+		return fields[0]
+	}
+	return ""
+}
+
+// the registered command closest to given (unknown) command
+//
+// (returns an empty string when nothing is close enough)
+func closestCommand(command string) string {
+	if !strings.HasPrefix(command, "/") {
+		return ""
+	}
+
+	closest, distance := "", maxSuggestionDistance+1
+	for _, d := range commandDescriptions {
+		if dist := levenshtein(strings.ToLower(command), d[0]); dist < distance {
+			closest, distance = d[0], dist
+		}
+	}
+	return prefixedCommand(closest)
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	bot "github.com/meinside/telegram-bot-go"
+)
+
+// set the username of this bot for a test
+func withBotUsername(t *testing.T, username string) {
+	saved, savedPattern := botUsername, mentionPattern
+	t.Cleanup(func() {
+		botUsername, mentionPattern = saved, savedPattern
+	})
+
+	setBotUsername(username)
+}
+
+func TestParseCommand(t *testing.T) {
+	withBotUsername(t, "bot")
+
+	tests := []struct {
+		txt     string
+		command string
+		args    string
+	}{
+		{"/execute", "/execute", ""},
+		{"/execute arg", "/execute", "arg"},
+		{"/execute  arg1 arg2 ", "/execute", "arg1 arg2"},
+		{"/execute\targ", "/execute", "arg"},
+		{"/executefoo", "/executefoo", ""},
+		{"/executefoo arg", "/executefoo", "arg"},
+		{"/execute@bot", "/execute", ""},
+		{"/execute@BOT arg", "/execute", "arg"},
+		{"/execute@other_bot", "/execute@other_bot", ""},
+		{"detect_face arg", "detect_face", "arg"},
+		{"", "", ""},
+	}
+
+	for _, test := range tests {
+		command, args := parseCommand(test.txt)
+		if command != test.command || args != test.args {
+			t.Errorf("parseCommand(%q) = (%q, %q), want (%q, %q)", test.txt, command, args, test.command, test.args)
+		}
+	}
+}
+
+func TestParseCommandMatchesHandlersExactly(t *testing.T) {
+	withBotUsername(t, "bot")
+
+	tests := []struct {
+		txt     string
+		handled bool
+	}{
+		{"/execute", true},
+		{"/execute arg", true},
+		{"/execute@bot", true},
+		{"/executefoo", false},
+		{"/execute@other_bot", false},
+	}
+
+	for _, test := range tests {
+		command, _ := parseCommand(test.txt)
+		if _, handled := commandHandlers[command]; handled != test.handled {
+			t.Errorf("%q handled: %t, want %t", test.txt, handled, test.handled)
+		}
+	}
+}
+
+func TestParseCommandWithCommandPrefix(t *testing.T) {
+	withBotUsername(t, "bot")
+
+	saved := commandPrefix
+	t.Cleanup(func() { commandPrefix = saved })
+
+	tests := []struct {
+		prefix  string
+		txt     string
+		command string
+		args    string
+	}{
+		{"cv", "/cv_execute arg", "/execute", "arg"},
+		{"cv", "/cv_execute@bot", "/execute", ""},
+		{"cv", "/execute arg", "", "/execute arg"}, // (without the prefix)
+		{"cv", "/cvexecute", "", "/cvexecute"},
+		{"cv", "/cv_", "", "/cv_"},
+		{"cv", "/start", "/start", ""},
+		{"s", "/scripts", "", "/scripts"},
+		{"s", "/status", "", "/status"},
+		{"s", "/s_scripts", "/scripts", ""},
+		{"my_cv", "/my_cv_status", "/status", ""},
+		{"", "/scripts", "/scripts", ""},
+	}
+
+	for _, test := range tests {
+		commandPrefix = test.prefix
+
+		command, args := parseCommand(test.txt)
+		if command != test.command || args != test.args {
+			t.Errorf("parseCommand(%q) with prefix %q = (%q, %q), want (%q, %q)", test.txt, test.prefix, command, args, test.command, test.args)
+		}
+	}
+}
+
+func TestPrefixedCommand(t *testing.T) {
+	saved := commandPrefix
+	t.Cleanup(func() { commandPrefix = saved })
+
+	commandPrefix = "cv"
+	if command := prefixedCommand(commandExecute); command != "/cv_execute" {
+		t.Errorf("prefixedCommand(%q) = %q, want %q", commandExecute, command, "/cv_execute")
+	}
+	if !hasPrefixedCommand(" /cv_execute arg") {
+		t.Errorf("expected a prefixed command")
+	}
+	if hasPrefixedCommand("/cvexecute") {
+		t.Errorf("expected no prefixed command")
+	}
+
+	commandPrefix = ""
+	if command := prefixedCommand(commandExecute); command != commandExecute {
+		t.Errorf("prefixedCommand(%q) = %q without prefix, want it as it is", commandExecute, command)
+	}
+}
+
+func TestCommandPrefixPattern(t *testing.T) {
+	for prefix, valid := range map[string]bool{
+		"cv":     true,
+		"my_cv":  true,
+		"cv2":    true,
+		"_cv":    false,
+		"cv__x":  false,
+		"CV":     false,
+		"cv-bot": false,
+	} {
+		if matched := commandPrefixPattern.MatchString(prefix); matched != valid {
+			t.Errorf("command prefix %q valid: %t, want %t", prefix, matched, valid)
+		}
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		distance int
+	}{
+		{"", "", 0},
+		{"/execute", "/execute", 0},
+		{"/exeucte", "/execute", 2},
+		{"/execut", "/execute", 1},
+		{"/stats", "/status", 1},
+		{"", "/help", 5},
+		{"가나다", "가다", 1}, // (counted in runes)
+	}
+
+	for _, test := range tests {
+		if distance := levenshtein(test.a, test.b); distance != test.distance {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", test.a, test.b, distance, test.distance)
+		}
+	}
+}
+
+func TestClosestCommand(t *testing.T) {
+	saved := commandPrefix
+	t.Cleanup(func() { commandPrefix = saved })
+	commandPrefix = ""
+
+	tests := []struct {
+		command string
+		closest string
+	}{
+		{"/exeucte", "/execute"},
+		{"/EXECUTE", "/execute"},
+		{"/hlep", "/help"},
+		{"/scirpts", "/scripts"},
+		{"/completely_different", ""}, // (not close enough)
+		{"/x", ""},
+		{"execute", ""}, // (not a command)
+		{"", ""},
+	}
+
+	for _, test := range tests {
+		if closest := closestCommand(test.command); closest != test.closest {
+			t.Errorf("closestCommand(%q) = %q, want %q", test.command, closest, test.closest)
+		}
+	}
+
+	// suggested with the command prefix
+	commandPrefix = "cv"
+	if closest := closestCommand("/exeucte"); closest != "/cv_execute" {
+		t.Errorf("closestCommand(%q) with prefix = %q, want %q", "/exeucte", closest, "/cv_execute")
+	}
+}
+
+// set scripts for a test
+func withScripts(t *testing.T, scriptsForTest map[string]Script) {
+	saved := scripts
+	t.Cleanup(func() { scripts = saved })
+
+	scripts = scriptsForTest
+}
+
+func TestPipelineRejectsScriptsWhichAsk(t *testing.T) {
+	withCooldowns(t, 0, 0, false, "tester")
+	withScripts(t, map[string]Script{
+		"capture":  {Path: "/bin/true"},
+		"detect":   {Path: "/bin/true"},
+		"erase":    {Path: "/bin/true", RequiresConfirmation: true},
+		"annotate": {Path: "/bin/true", RequireReason: true},
+	})
+
+	tests := []struct {
+		args     string
+		rejected bool
+	}{
+		{"capture|detect", false},
+		{"capture|erase", true},
+		{"capture|annotate", true},
+	}
+
+	for _, test := range tests {
+		c := &CommandContext{
+			Message: &bot.Message{Chat: bot.Chat{ID: 1}},
+			UserID:  "tester",
+			Args:    test.args,
+			Session: pool.Sessions["tester"],
+			Options: map[string]interface{}{},
+		}
+		handlePipeline(c)
+
+		if rejected := c.Request == nil; rejected != test.rejected {
+			t.Errorf("%q rejected: %t, want %t (reply: %q)", test.args, rejected, test.rejected, c.Reply)
+		}
+		if test.rejected && !strings.HasPrefix(c.Reply, "Scripts which require a reason or confirmation") {
+			t.Errorf("%q: unexpected reply: %q", test.args, c.Reply)
+		}
+	}
+}
+
+func TestExecuteChecksInlineParameterValues(t *testing.T) {
+	withCooldowns(t, 0, 0, false, "tester")
+
+	saved := argumentPattern
+	t.Cleanup(func() { argumentPattern = saved })
+	argumentPattern = regexp.MustCompile(defaultArgumentPattern)
+	withScripts(t, map[string]Script{
+		"detect": {
+			Path: "/bin/true",
+			Parameters: []ScriptParameter{
+				{Name: "mode", Values: []string{"fast", "quality"}},
+				{Name: "size", Values: []string{"small", "large"}},
+			},
+		},
+	})
+
+	tests := []struct {
+		args     string
+		executed bool
+	}{
+		{"detect --mode fast", true},
+		{"detect --mode quality --size large", true},
+		{"detect --mode slow", false},
+		{"detect --mode fast --size huge", false},
+		{"detect --unknown fast", false},
+		{"detect --mode", false},
+		{"detect fast", false},
+	}
+
+	for _, test := range tests {
+		c := &CommandContext{
+			Message: &bot.Message{Chat: bot.Chat{ID: 1}},
+			UserID:  "tester",
+			Args:    test.args,
+			Session: pool.Sessions["tester"],
+			Options: map[string]interface{}{},
+		}
+		handleExecute(c)
+
+		if executed := c.Request != nil; executed != test.executed {
+			t.Errorf("%q executed: %t, want %t (reply: %q)", test.args, executed, test.executed, c.Reply)
+		}
+		if !test.executed && !strings.HasPrefix(c.Reply, messageInvalidValue) {
+			t.Errorf("%q: expected %q, got: %q", test.args, messageInvalidValue, c.Reply)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+// loading config files in JSON, YAML, or TOML
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// config filenames to look for, in order of precedence
+var configFilenames = []string{
+	configFilename,
+	"config.yaml",
+	"config.yml",
+	"config.toml",
+}
+
+// find the config file in given directory
+//
+// (falls back to the default config file when none of them exists)
+func findConfigFile(dir string) string {
+	for _, filename := range configFilenames {
+		path := filepath.Join(dir, filename)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return filepath.Join(dir, configFilename)
+}
+
+// read and parse the config file at given path
+func loadConfig(path string) (config Config, err error) {
+	file, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+
+	return parseConfig(path, file)
+}
+
+// parse given config bytes, in the format of given filename's extension (JSON when it has none)
+//
+// (YAML and TOML are converted to JSON first, so the same field names are used in all formats)
+func parseConfig(filename string, data []byte) (config Config, err error) {
+	var generic interface{}
+
+	switch ext := strings.ToLower(filepath.Ext(filename)); ext {
+	case ".json", "":
+		err = json.Unmarshal(data, &config)
+		return config, err
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &generic); err != nil {
+			return Config{}, fmt.Errorf("failed to parse yaml: %s", err)
+		}
+	case ".toml":
+		table := map[string]interface{}{}
+		if err := toml.Unmarshal(data, &table); err != nil {
+			return Config{}, fmt.Errorf("failed to parse toml: %s", err)
+		}
+		generic = table
+	default:
+		return Config{}, fmt.Errorf("unsupported format of config file: %s", ext)
+	}
+
+	if data, err = json.Marshal(generic); err != nil {
+		return Config{}, fmt.Errorf("failed to convert config: %s", err)
+	}
+	err = json.Unmarshal(data, &config)
+	return config, err
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseConfigInAllFormats(t *testing.T) {
+	configs := map[string]string{
+		"config.json": `{
+	"api_token": "0123456789:abcdefg",
+	"allowed_ids": ["telegram_id_1", "telegram_id_2"],
+	"monitor_interval": 5,
+	"is_verbose": true,
+	"scripts": {
+		"detect_face": "/home/pi/detect_face.py",
+		"ipcam": {
+			"path": "/home/pi/capture_rtsp.py",
+			"timeout_seconds": 30,
+			"env": {"CAMERA_TOKEN": "${IPCAM_TOKEN}"}
+		}
+	},
+	"webhook": {"host": "my.host.com", "port": 8443}
+}`,
+		"config.yaml": `
+api_token: "0123456789:abcdefg"
+allowed_ids:
+  - telegram_id_1
+  - telegram_id_2
+monitor_interval: 5
+is_verbose: true
+scripts:
+  detect_face: /home/pi/detect_face.py
+  ipcam:
+    path: /home/pi/capture_rtsp.py
+    timeout_seconds: 30
+    env:
+      CAMERA_TOKEN: "${IPCAM_TOKEN}"
+webhook:
+  host: my.host.com
+  port: 8443
+`,
+		"config.toml": `
+api_token = "0123456789:abcdefg"
+allowed_ids = ["telegram_id_1", "telegram_id_2"]
+monitor_interval = 5
+is_verbose = true
+
+[scripts]
+detect_face = "/home/pi/detect_face.py"
+
+[scripts.ipcam]
+path = "/home/pi/capture_rtsp.py"
+timeout_seconds = 30
+
+[scripts.ipcam.env]
+CAMERA_TOKEN = "${IPCAM_TOKEN}"
+
+[webhook]
+host = "my.host.com"
+port = 8443
+`,
+	}
+
+	expected := Config{
+		APIToken:        "0123456789:abcdefg",
+		AllowedIds:      []string{"telegram_id_1", "telegram_id_2"},
+		MonitorInterval: 5,
+		IsVerbose:       true,
+		Scripts: map[string]Script{
+			"detect_face": {Path: "/home/pi/detect_face.py"}, // (given as a path string)
+			"ipcam": {
+				Path:           "/home/pi/capture_rtsp.py",
+				TimeoutSeconds: 30,
+				Env:            map[string]string{"CAMERA_TOKEN": "${IPCAM_TOKEN}"},
+			},
+		},
+		Webhook: &WebhookConfig{Host: "my.host.com", Port: 8443},
+	}
+
+	for filename, content := range configs {
+		config, err := parseConfig(filename, []byte(content))
+		if err != nil {
+			t.Errorf("%s: failed to parse config: %s", filename, err)
+			continue
+		}
+		if !reflect.DeepEqual(config, expected) {
+			t.Errorf("%s: parsed config = %+v, want %+v", filename, config, expected)
+		}
+	}
+}
+
+func TestParseConfigWithUnknownExtension(t *testing.T) {
+	if _, err := parseConfig("config.ini", []byte("api_token = 0123456789:abcdefg")); err == nil || !strings.Contains(err.Error(), ".ini") {
+		t.Errorf("expected an error for an unknown extension, got: %v", err)
+	}
+	if _, err := parseConfig("config", []byte(`{"api_token": "0123456789:abcdefg"}`)); err != nil {
+		t.Errorf("expected a config without extension to be parsed as json, got: %s", err)
+	}
+}
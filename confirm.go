@@ -0,0 +1,107 @@
+// confirmations of scripts which should not be executed by mistake (eg. long-running ones)
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	bot "github.com/meinside/telegram-bot-go"
+)
+
+const (
+	defaultConfirmationTimeoutSeconds = 60
+)
+
+// Confirmation struct for a request waiting for a confirmation
+type Confirmation struct {
+	Request     ExecuteRequest
+	RequestedAt time.Time
+}
+
+// Confirmations struct for requests waiting for confirmations
+type Confirmations struct {
+	pending map[string]Confirmation // id => confirmation
+	lastID  int
+	sync.Mutex
+}
+
+// requests waiting for confirmations
+var confirmations = Confirmations{
+	pending: map[string]Confirmation{},
+}
+
+// keep given request until it is confirmed, and return a message and an inline keyboard for confirming it
+func (c *Confirmations) add(request ExecuteRequest, now time.Time) (message string, keyboard bot.InlineKeyboardMarkup) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.expire(now)
+
+	c.lastID++
+	id := strconv.Itoa(c.lastID)
+	c.pending[id] = Confirmation{
+		Request:     request,
+		RequestedAt: now,
+	}
+
+	name := request.ScriptName
+	if len(name) <= 0 {
+		name = request.ScriptPath
+	}
+
+	confirm, deny := callbackPrefixConfirm+id, callbackPrefixDeny+id
+
+	return fmt.Sprintf(messageConfirmFormat, name, confirmationTimeoutSeconds), bot.InlineKeyboardMarkup{
+		InlineKeyboard: [][]bot.InlineKeyboardButton{
+			{
+				{Text: buttonConfirm, CallbackData: &confirm},
+				{Text: buttonDeny, CallbackData: &deny},
+			},
+		},
+	}
+}
+
+// take the request with given id out of the confirmations
+//
+// returns a message for the user when it is expired, or requested by another user
+func (c *Confirmations) take(id, userID string, now time.Time) (request ExecuteRequest, message string) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.expire(now)
+
+	confirmation, exists := c.pending[id]
+	if !exists {
+		return request, messageConfirmationExpired
+	}
+	if confirmation.Request.UserID != userID {
+		return request, messageNotYourConfirmation
+	}
+	delete(c.pending, id)
+
+	return confirmation.Request, ""
+}
+
+// forget confirmations which are timed out
+//
+// (should be called while holding the lock)
+func (c *Confirmations) expire(now time.Time) {
+	timeout := time.Duration(confirmationTimeoutSeconds) * time.Second
+
+	for id, confirmation := range c.pending {
+		if now.Sub(confirmation.RequestedAt) >= timeout {
+			log.Printf("Confirmation expired for id: %s", confirmation.Request.UserID)
+
+			delete(c.pending, id)
+		}
+	}
+}
+
+// ask for a confirmation before executing given request
+func awaitConfirmation(request ExecuteRequest) (message string, keyboard bot.InlineKeyboardMarkup) {
+	return confirmations.add(request, time.Now())
+}
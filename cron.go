@@ -0,0 +1,138 @@
+// cron expressions for schedules
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	maxCronSearchYears = 5 // how far to look for the next matching time
+)
+
+// CronExpression struct for a parsed cron expression: "minute hour day-of-month month day-of-week"
+type CronExpression struct {
+	minutes     map[int]bool
+	hours       map[int]bool
+	daysOfMonth map[int]bool
+	months      map[int]bool
+	daysOfWeek  map[int]bool
+
+	// whether day-of-month/day-of-week are restricted (not '*')
+	domRestricted bool
+	dowRestricted bool
+}
+
+// parse a field of cron expression with given range of values
+//
+// supports: *, 5, 1-5, */15, 1-30/5, and comma-separated lists of them
+//
+// (fields starting with '*' are not restricted, as in cron)
+func parseCronField(field string, min, max int) (values map[int]bool, restricted bool, err error) {
+	values = map[int]bool{}
+
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step <= 0 {
+				return nil, false, fmt.Errorf("invalid step: '%s'", part)
+			}
+			part = part[:i]
+		}
+
+		from, to := min, max
+		if part != "*" {
+			restricted = true
+
+			bounds := strings.SplitN(part, "-", 2)
+			if from, err = strconv.Atoi(bounds[0]); err != nil {
+				return nil, false, fmt.Errorf("invalid value: '%s'", part)
+			}
+			to = from
+			if len(bounds) > 1 {
+				if to, err = strconv.Atoi(bounds[1]); err != nil {
+					return nil, false, fmt.Errorf("invalid value: '%s'", part)
+				}
+			} else if step > 1 {
+				to = max // eg. 5/15 = 5-max/15
+			}
+			if from < min || to > max || from > to {
+				return nil, false, fmt.Errorf("out of range (%d-%d): '%s'", min, max, part)
+			}
+		}
+
+		for v := from; v <= to; v += step {
+			values[v] = true
+		}
+	}
+
+	return values, restricted, nil
+}
+
+// parse given cron expression
+func parseCron(expression string) (cron CronExpression, err error) {
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return cron, fmt.Errorf("expected 5 fields (minute hour day-of-month month day-of-week), got %d", len(fields))
+	}
+
+	if cron.minutes, _, err = parseCronField(fields[0], 0, 59); err != nil {
+		return cron, fmt.Errorf("minute: %s", err)
+	}
+	if cron.hours, _, err = parseCronField(fields[1], 0, 23); err != nil {
+		return cron, fmt.Errorf("hour: %s", err)
+	}
+	if cron.daysOfMonth, cron.domRestricted, err = parseCronField(fields[2], 1, 31); err != nil {
+		return cron, fmt.Errorf("day of month: %s", err)
+	}
+	if cron.months, _, err = parseCronField(fields[3], 1, 12); err != nil {
+		return cron, fmt.Errorf("month: %s", err)
+	}
+	if cron.daysOfWeek, cron.dowRestricted, err = parseCronField(fields[4], 0, 7); err != nil {
+		return cron, fmt.Errorf("day of week: %s", err)
+	}
+	if cron.daysOfWeek[7] { // both 0 and 7 are sunday
+		cron.daysOfWeek[0] = true
+	}
+
+	return cron, nil
+}
+
+// check if given day matches
+//
+// (when both day-of-month and day-of-week are restricted, either of them can match, as in cron)
+func (c CronExpression) matchesDay(t time.Time) bool {
+	dom, dow := c.daysOfMonth[t.Day()], c.daysOfWeek[int(t.Weekday())]
+
+	if c.domRestricted && c.dowRestricted {
+		return dom || dow
+	}
+	return dom && dow
+}
+
+// the first matching time after given time
+//
+// (returns a zero time when nothing matches)
+func (c CronExpression) next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := after.AddDate(maxCronSearchYears, 0, 0)
+
+	for t.Before(limit) {
+		if !c.months[int(t.Month())] {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		} else if !c.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		} else if !c.hours[t.Hour()] {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		} else if !c.minutes[t.Minute()] {
+			t = t.Add(time.Minute)
+		} else {
+			return t
+		}
+	}
+
+	return time.Time{}
+}
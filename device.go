@@ -0,0 +1,123 @@
+// locks of devices (cameras) used by scripts
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultDevice = "" // device of scripts without one
+)
+
+// locks for making sure each device is not used simultaneously
+var deviceLocks = map[string]*sync.Mutex{}
+var deviceLocksLock sync.Mutex
+
+// when each device was released last (for cooldowns between accesses)
+var deviceReleasedAt = map[string]time.Time{}
+
+// DeviceUsage struct for the execution which is using a device
+type DeviceUsage struct {
+	UserID     string
+	ScriptName string
+	StartedAt  time.Time
+}
+
+// executions using each device
+var deviceUsages = map[string]DeviceUsage{}
+
+// get the lock of given device
+func deviceLock(device string) *sync.Mutex {
+	deviceLocksLock.Lock()
+	defer deviceLocksLock.Unlock()
+
+	lock, exists := deviceLocks[device]
+	if !exists {
+		lock = &sync.Mutex{}
+		deviceLocks[device] = lock
+	}
+
+	return lock
+}
+
+// mark given device as being used by given request
+func markDeviceInUse(device string, request ExecuteRequest) {
+	deviceLocksLock.Lock()
+	defer deviceLocksLock.Unlock()
+
+	deviceUsages[device] = DeviceUsage{
+		UserID:     request.UserID,
+		ScriptName: request.ScriptName,
+		StartedAt:  time.Now(),
+	}
+}
+
+// mark given device as released now
+func markDeviceReleased(device string) {
+	deviceLocksLock.Lock()
+	defer deviceLocksLock.Unlock()
+
+	deviceReleasedAt[device] = time.Now()
+	delete(deviceUsages, device)
+}
+
+// remaining cooldown of given device, before it can be used again
+func deviceCooldown(device string) time.Duration {
+	deviceLocksLock.Lock()
+	defer deviceLocksLock.Unlock()
+
+	return remainingCooldown(deviceReleasedAt[device], time.Duration(cameraCooldownSeconds)*time.Second, time.Now())
+}
+
+// describe whether each device is busy or not, with the running execution (and its user, when withUser is true)
+func describeDevices(withUser bool) string {
+	deviceLocksLock.Lock()
+	devices := []string{defaultDevice}
+	for device := range deviceLocks {
+		if device != defaultDevice {
+			devices = append(devices, device)
+		}
+	}
+	sort.Strings(devices[1:])
+	deviceLocksLock.Unlock()
+
+	lines := []string{}
+	for _, device := range devices {
+		name := device
+		if name == defaultDevice {
+			name = "default"
+		}
+
+		// (not busy when the lock can be taken right away)
+		lock := deviceLock(device)
+		if lock.TryLock() {
+			lock.Unlock()
+
+			lines = append(lines, fmt.Sprintf(messageDeviceFreeFormat, name))
+			continue
+		}
+
+		deviceLocksLock.Lock()
+		usage, exists := deviceUsages[device]
+		deviceLocksLock.Unlock()
+
+		if !exists {
+			// (locked by others, eg. camera reset)
+			lines = append(lines, fmt.Sprintf(messageDeviceBusyFormat, name))
+			continue
+		}
+
+		line := fmt.Sprintf(messageDeviceInUseFormat, name, usage.ScriptName, time.Since(usage.StartedAt).Round(time.Second))
+		if withUser {
+			line += fmt.Sprintf(messageDeviceUserFormat, usage.UserID)
+		}
+		lines = append(lines, line)
+	}
+
+	return strings.Join(lines, "\n")
+}
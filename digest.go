@@ -0,0 +1,129 @@
+// scheduled digests of executions
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	bot "github.com/meinside/telegram-bot-go"
+)
+
+const (
+	digestTimeFormat = "15:04" // HH:MM
+)
+
+// ExecutionStats struct for collecting execution statistics between digests
+type ExecutionStats struct {
+	Since      time.Time
+	NumTotal   int
+	NumFailed  int
+	LastImage  []byte // representative image for the digest
+	LastFailed time.Time
+	sync.Mutex
+}
+
+// statistics since the last digest
+var stats = ExecutionStats{
+	Since: time.Now(),
+}
+
+// record the result of an execution
+func (s *ExecutionStats) record(succeeded bool, image []byte) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.NumTotal++
+	if !succeeded {
+		s.NumFailed++
+		s.LastFailed = time.Now()
+	}
+	if image != nil {
+		s.LastImage = image
+	}
+}
+
+// summarize statistics and reset them for the next window
+func (s *ExecutionStats) summarizeAndReset() (summary string, image []byte) {
+	s.Lock()
+	defer s.Unlock()
+
+	now := time.Now()
+
+	summary = fmt.Sprintf("Digest (%s ~ %s)\n\nExecutions: %d\nSucceeded: %d\nFailed: %d",
+		s.Since.Format(time.RFC3339),
+		now.Format(time.RFC3339),
+		s.NumTotal,
+		s.NumTotal-s.NumFailed,
+		s.NumFailed,
+	)
+	if !s.LastFailed.IsZero() {
+		summary += fmt.Sprintf("\nLast failure: %s", s.LastFailed.Format(time.RFC3339))
+	}
+	image = s.LastImage
+
+	// reset the window
+	s.Since = now
+	s.NumTotal = 0
+	s.NumFailed = 0
+	s.LastImage = nil
+	s.LastFailed = time.Time{}
+
+	return summary, image
+}
+
+// calculate the next time of given clock time ("HH:MM") after now
+func nextDigestTime(clock string, now time.Time) (time.Time, error) {
+	t, err := time.Parse(digestTimeFormat, clock)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	next := time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+
+	return next, nil
+}
+
+// send digests at the configured time, every day
+func runDigests(b BotClient) {
+	for {
+		next, err := nextDigestTime(digestTime, time.Now())
+		if err != nil {
+			log.Printf("*** Invalid digest time: %s (%s)", digestTime, err)
+			return
+		}
+
+		time.Sleep(time.Until(next))
+
+		sendDigest(b)
+	}
+}
+
+// compile statistics and send them to the digest chat
+func sendDigest(b BotClient) bool {
+	summary, image := stats.summarizeAndReset()
+
+	if image != nil {
+		b.SendChatAction(digestChatID, bot.ChatActionUploadPhoto)
+
+		sent := b.SendPhoto(digestChatID, bot.InputFileFromBytes(image), map[string]interface{}{
+			"caption": summary,
+		})
+		if sent.Ok {
+			return true
+		}
+		log.Printf("*** Failed to send digest photo: %s", *sent.Description)
+	}
+
+	sent := b.SendMessage(digestChatID, summary, nil)
+	if !sent.Ok {
+		log.Printf("*** Failed to send digest: %s", *sent.Description)
+	}
+
+	return sent.Ok
+}
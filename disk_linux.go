@@ -0,0 +1,20 @@
+//go:build linux
+// +build linux
+
+// free disk space (linux only)
+
+package main
+
+import (
+	"syscall"
+)
+
+// get free disk space of the filesystem containing given path, in bytes
+func freeDiskSpace(path string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+
+	return stat.Bavail * uint64(stat.Bsize), nil
+}
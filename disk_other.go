@@ -0,0 +1,15 @@
+//go:build !linux
+// +build !linux
+
+// free disk space (not supported on platforms other than linux)
+
+package main
+
+import (
+	"fmt"
+)
+
+// get free disk space of the filesystem containing given path, in bytes
+func freeDiskSpace(path string) (uint64, error) {
+	return 0, fmt.Errorf("checking disk space is not supported on this platform")
+}
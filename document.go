@@ -0,0 +1,78 @@
+// sending outputs of scripts as documents
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	bot "github.com/meinside/telegram-bot-go"
+)
+
+const (
+	messageChunkBytes = 4000 // max bytes of a message, under the limit of 4096 characters
+	maxMessageChunks  = 10   // texts longer than this number of messages will be sent as documents
+
+	documentBasename         = "output"
+	defaultDocumentExtension = ".bin"
+)
+
+// file extensions of mime types
+var documentExtensions = map[string]string{
+	"application/pdf":          ".pdf",
+	"application/zip":          ".zip",
+	"application/x-gzip":       ".gz",
+	"application/json":         ".json",
+	"application/octet-stream": ".bin",
+	"text/plain":               ".txt",
+	"text/csv":                 ".csv",
+	"text/html":                ".html",
+	"text/xml":                 ".xml",
+	"audio/mpeg":               ".mp3",
+	"audio/wave":               ".wav",
+	"image/jpeg":               ".jpg",
+	"image/png":                ".png",
+	"image/bmp":                ".bmp",
+	"video/mp4":                ".mp4",
+	"video/webm":               ".webm",
+	"video/avi":                ".avi",
+	mimeWebP:                   ".webp",
+	mimeHEIC:                   ".heic",
+	mimeAVIF:                   ".avif",
+	mimeGIF:                    ".gif",
+}
+
+// check if given mime type is of plain text
+func isPlainText(mime string) bool {
+	return strings.HasPrefix(mime, "text/plain")
+}
+
+// generate a filename for a document of given mime type
+func documentFilename(mime string) string {
+	mime = strings.TrimSpace(strings.Split(mime, ";")[0]) // remove parameters, eg. "; charset=utf-8"
+
+	if ext, exists := documentExtensions[mime]; exists {
+		return documentBasename + ext
+	}
+	return documentBasename + defaultDocumentExtension
+}
+
+// send given bytes as a document with a filename
+//
+// (files from bytes are named after the parameter by the bot library, so it is written to a temporary file first)
+func sendDocumentWithFilename(b BotClient, chatID bot.ChatID, bytes []byte, filename string, options map[string]interface{}) bot.APIResponseMessage {
+	dir, err := ioutil.TempDir(tempDir, "telegram-bot-opencv")
+	if err != nil {
+		return b.SendDocument(chatID, bot.InputFileFromBytes(bytes), options)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, filename)
+	if err := ioutil.WriteFile(path, bytes, 0600); err != nil {
+		return b.SendDocument(chatID, bot.InputFileFromBytes(bytes), options)
+	}
+
+	return b.SendDocument(chatID, bot.InputFileFromFilepath(path), options)
+}
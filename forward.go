@@ -0,0 +1,115 @@
+// forwarding execution results to an HTTP endpoint
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"time"
+)
+
+const (
+	resultWebhookTimeoutSeconds = 30
+
+	defaultResultWebhookRetries        = 3
+	defaultResultWebhookBackoffSeconds = 2
+
+	// multipart field names
+	resultFieldPayload = "payload"
+	resultFieldOutput  = "output"
+)
+
+// ResultPayload struct for forwarding an execution result
+type ResultPayload struct {
+	UserID    string    `json:"user_id"`
+	Script    string    `json:"script"`
+	Mime      string    `json:"mime"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// http client for forwarding results
+var resultHTTPClient = &http.Client{
+	Timeout: resultWebhookTimeoutSeconds * time.Second,
+}
+
+// forward an execution result to the configured webhook asynchronously,
+// retrying with exponential backoff and alerting admins when all retries fail
+//
+// (does nothing when no webhook is configured)
+func forwardResult(b BotClient, request ExecuteRequest, mime string, output []byte) {
+	if len(resultWebhook) <= 0 {
+		return
+	}
+
+	script := request.ScriptName
+	if len(script) <= 0 {
+		script = request.ScriptPath
+	}
+
+	payload := ResultPayload{
+		UserID:    request.UserID,
+		Script:    script,
+		Mime:      mime,
+		Timestamp: time.Now(),
+	}
+
+	go func() {
+		var err error
+
+		backoff := time.Duration(resultWebhookBackoffSeconds) * time.Second
+		for i := 0; i <= resultWebhookRetries; i++ {
+			if i > 0 {
+				time.Sleep(backoff)
+				backoff *= 2
+			}
+
+			if err = postResult(resultWebhook, payload, output); err == nil {
+				return
+			}
+
+			logEvent("*** Failed to forward result to webhook", map[string]interface{}{"user_id": payload.UserID, "script": payload.Script, "try": i + 1, "tries": resultWebhookRetries + 1, "error": err.Error()})
+		}
+
+		notifyAdmins(b, fmt.Sprintf("Failed to forward result of '%s' (by %s) to webhook: %s", payload.Script, payload.UserID, err))
+	}()
+}
+
+// post given payload and output to the url as a multipart form
+func postResult(url string, payload ResultPayload, output []byte) error {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	if err := writer.WriteField(resultFieldPayload, string(payloadBytes)); err != nil {
+		return err
+	}
+
+	part, err := writer.CreateFormFile(resultFieldOutput, resultFieldOutput)
+	if err != nil {
+		return err
+	}
+	if _, err := part.Write(output); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	resp, err := resultHTTPClient.Post(url, writer.FormDataContentType(), body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,61 @@
+// messages in group chats
+
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	bot "github.com/meinside/telegram-bot-go"
+)
+
+// username and pattern of mentions of this bot (set on launch)
+var botUsername string
+var mentionPattern *regexp.Regexp
+
+// set the username of this bot
+func setBotUsername(username string) {
+	botUsername = username
+	mentionPattern = regexp.MustCompile(`(?i)@` + regexp.QuoteMeta(username) + `\b`) // case-insensitive
+}
+
+// check if given username (without the leading '@') is of this bot
+//
+// (all usernames are regarded as this bot's when it is not known yet)
+func isBotUsername(username string) bool {
+	return len(botUsername) <= 0 || strings.EqualFold(username, botUsername)
+}
+
+// check if given message is for this bot
+//
+// messages in group chats should mention the bot (eg. @some_bot), have commands targeting it (eg. /execute@some_bot),
+// or have commands with the command prefix (eg. /cv_execute),
+// while all messages in private chats are for the bot
+func isAddressedToBot(message *bot.Message) bool {
+	if message.Chat.Type == bot.ChatTypePrivate || mentionPattern == nil {
+		return true
+	}
+	txt, exists := messageText(message)
+	if !exists {
+		return false
+	}
+
+	return mentionPattern.MatchString(txt) || hasPrefixedCommand(txt)
+}
+
+// strip mentions of this bot from given text
+//
+// eg. "@some_bot /execute" => "/execute", "/execute@some_bot" => "/execute"
+func stripMention(txt string) string {
+	if mentionPattern == nil {
+		return txt
+	}
+	return strings.TrimSpace(mentionPattern.ReplaceAllString(txt, ""))
+}
+
+// check if given message is sent by a user (not by a channel, or an anonymous group admin)
+//
+// (messages sent on behalf of chats have their sender chats, and no senders or placeholder users as their senders)
+func isSentByUser(message *bot.Message) bool {
+	return message.From != nil && message.SenderChat == nil
+}
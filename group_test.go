@@ -0,0 +1,51 @@
+package main
+
+import (
+	"sync/atomic"
+	"testing"
+
+	bot "github.com/meinside/telegram-bot-go"
+)
+
+func TestUpdatesWithoutUserSendersAreIgnored(t *testing.T) {
+	withCooldowns(t, 0, 0, false, "tester")
+
+	txt := commandExecute
+	placeholder := &bot.User{ID: 1087968824, Username: &[]string{"GroupAnonymousBot"}[0]}
+	tests := []struct {
+		name    string
+		message *bot.Message
+	}{
+		{"channel post without a sender", &bot.Message{
+			Chat: bot.Chat{ID: -1001, Type: bot.ChatTypeChannel},
+			Text: &txt,
+		}},
+		{"anonymous group admin", &bot.Message{
+			From:       placeholder,
+			SenderChat: &bot.Chat{ID: -1002, Type: bot.ChatTypeGroup},
+			Chat:       bot.Chat{ID: -1002, Type: bot.ChatTypeGroup},
+			Text:       &txt,
+		}},
+		{"message on behalf of a channel", &bot.Message{
+			From:       &bot.User{ID: 136817688},
+			SenderChat: &bot.Chat{ID: -1003, Type: bot.ChatTypeChannel},
+			Chat:       bot.Chat{ID: -1004, Type: bot.ChatTypeGroup},
+			Text:       &txt,
+		}},
+	}
+
+	for _, test := range tests {
+		fake := &FakeBotClient{}
+		queued := atomic.LoadInt32(&queueLength)
+
+		if processed := processUpdate(fake, bot.Update{Message: test.message}); processed {
+			t.Errorf("%s: expected the update to be ignored", test.name)
+		}
+		if len(fake.Calls) > 0 {
+			t.Errorf("%s: expected no calls to the bot API, got: %+v", test.name, fake.Calls)
+		}
+		if atomic.LoadInt32(&queueLength) != queued {
+			t.Errorf("%s: expected nothing to be executed", test.name)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+// health check endpoint for monitoring
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	healthPath            = "/healthz"
+	healthShutdownTimeout = 5 * time.Second
+)
+
+// HealthStatus struct for the response of the health check endpoint
+type HealthStatus struct {
+	UptimeSeconds int64      `json:"uptime_seconds"`
+	QueueDepth    int32      `json:"queue_depth"`
+	Running       int        `json:"running"`
+	LastRun       *time.Time `json:"last_run,omitempty"`
+	LastRunOk     *bool      `json:"last_run_ok,omitempty"`
+	ShuttingDown  bool       `json:"shutting_down,omitempty"`
+}
+
+// health check server (nil when not enabled)
+var healthServer *http.Server
+
+// current health status of the bot
+func healthStatus() HealthStatus {
+	status := HealthStatus{
+		UptimeSeconds: int64(time.Since(startTime).Seconds()),
+		QueueDepth:    atomic.LoadInt32(&queueLength),
+		Running:       numRunning(),
+		ShuttingDown:  isShuttingDown(),
+	}
+
+	lastRun.Lock()
+	if !lastRun.FinishedAt.IsZero() {
+		finishedAt, succeeded := lastRun.FinishedAt, lastRun.Succeeded
+		status.LastRun, status.LastRunOk = &finishedAt, &succeeded
+	}
+	lastRun.Unlock()
+
+	return status
+}
+
+// respond with the health status (503 while shutting down)
+func handleHealth(w http.ResponseWriter, r *http.Request) {
+	status := healthStatus()
+
+	w.Header().Set("Content-Type", "application/json")
+	if status.ShuttingDown {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		log.Printf("*** Failed to write health status: %s", err)
+	}
+}
+
+// start the health check (and metrics) server on given port, independently of polling or webhook
+func startHealthServer(port int) {
+	mux := http.NewServeMux()
+	mux.HandleFunc(healthPath, handleHealth)
+	mux.HandleFunc(metricsPath, handleMetrics)
+
+	healthServer = &http.Server{
+		Addr:    fmt.Sprintf(":%d", port),
+		Handler: mux,
+	}
+
+	go func() {
+		log.Printf("Serving health checks on :%d%s (and metrics on %s)", port, healthPath, metricsPath)
+
+		if err := healthServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("*** Health check server failed: %s", err)
+		}
+	}()
+}
+
+// stop the health check server, if it is running
+func stopHealthServer() {
+	if healthServer == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), healthShutdownTimeout)
+	defer cancel()
+
+	if err := healthServer.Shutdown(ctx); err != nil {
+		log.Printf("*** Failed to stop health check server: %s", err)
+	}
+}
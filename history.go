@@ -0,0 +1,189 @@
+// history of executions
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultHistoryFilename = "history.json"
+	defaultHistorySize     = 100 // max number of executions kept in the history
+	defaultHistoryCount    = 10  // number of executions shown with /history
+	maxHistoryCount        = 30  // max number of executions shown with /history (for fitting in a message)
+)
+
+// HistoryEntry struct for an execution in the history
+type HistoryEntry struct {
+	Timestamp  time.Time `json:"timestamp"`
+	UserID     string    `json:"user_id"`
+	Script     string    `json:"script"`
+	Args       []string  `json:"args,omitempty"`
+	ExitCode   int       `json:"exit_code"`
+	DurationMs int64     `json:"duration_ms"`
+}
+
+// History struct for a ring buffer of executions
+type History struct {
+	entries []HistoryEntry
+	next    int // index of the next entry to be written
+	full    bool
+	sync.Mutex
+}
+
+// history of executions
+var history History
+
+// default path of the history file (next to given config file)
+func defaultHistoryPath(configFile string) string {
+	return defaultStatePath(configFile, defaultHistoryFilename)
+}
+
+// exit code of a script from its error
+//
+// (-1 when it did not exit by itself, eg. timed out or failed to start)
+func exitCodeOf(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
+
+// resize the ring buffer, keeping the latest entries
+func (h *History) resize(size int) {
+	h.Lock()
+	defer h.Unlock()
+
+	entries := h.ordered()
+	if len(entries) > size {
+		entries = entries[len(entries)-size:]
+	}
+
+	h.entries = make([]HistoryEntry, size)
+	copy(h.entries, entries)
+	h.next = len(entries) % size
+	h.full = len(entries) == size
+}
+
+// entries in chronological order
+//
+// (should be called while holding the lock)
+func (h *History) ordered() []HistoryEntry {
+	if !h.full {
+		return append([]HistoryEntry{}, h.entries[:h.next]...)
+	}
+	return append(append([]HistoryEntry{}, h.entries[h.next:]...), h.entries[:h.next]...)
+}
+
+// add an entry to the history, overwriting the oldest one when full
+func (h *History) record(entry HistoryEntry) {
+	h.Lock()
+	defer h.Unlock()
+
+	if len(h.entries) <= 0 {
+		return
+	}
+
+	h.entries[h.next] = entry
+	h.next = (h.next + 1) % len(h.entries)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// the last (at most) count entries of given user, newest first
+//
+// (empty user id for all users)
+func (h *History) last(userID string, count int) []HistoryEntry {
+	h.Lock()
+	defer h.Unlock()
+
+	entries := h.ordered()
+
+	result := []HistoryEntry{}
+	for i := len(entries) - 1; i >= 0 && len(result) < count; i-- {
+		if len(userID) <= 0 || entries[i].UserID == userID {
+			result = append(result, entries[i])
+		}
+	}
+	return result
+}
+
+// load the history from the file
+//
+// (a missing file is not an error)
+func (h *History) load() error {
+	file, err := ioutil.ReadFile(historyPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	entries := []HistoryEntry{}
+	if err := json.Unmarshal(file, &entries); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		h.record(entry)
+	}
+
+	return nil
+}
+
+// save the history to the file
+func (h *History) save() {
+	h.Lock()
+	entries := h.ordered()
+	h.Unlock()
+
+	file, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		log.Printf("*** Failed to marshal history: %s", err)
+		return
+	}
+
+	// write to a temporary file first, for not leaving a broken file behind
+	tmp := historyPath + ".tmp"
+	if err := ioutil.WriteFile(tmp, file, 0600); err != nil {
+		log.Printf("*** Failed to write history: %s", err)
+		return
+	}
+	if err := os.Rename(tmp, historyPath); err != nil {
+		log.Printf("*** Failed to save history: %s", err)
+	}
+}
+
+// format given history entries
+func formatHistory(entries []HistoryEntry, withUserIDs bool) string {
+	if len(entries) <= 0 {
+		return messageNoHistory
+	}
+
+	lines := []string{}
+	for _, entry := range entries {
+		line := fmt.Sprintf("%s %s", entry.Timestamp.Format("2006-01-02 15:04:05"), entry.Script)
+		if len(entry.Args) > 0 {
+			line += " " + strings.Join(entry.Args, " ")
+		}
+		line += fmt.Sprintf(" (exit: %d, %dms)", entry.ExitCode, entry.DurationMs)
+		if withUserIDs {
+			line += " by " + entry.UserID
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,155 @@
+// cache of the last outputs, for resending them without executing scripts again
+
+package main
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	bot "github.com/meinside/telegram-bot-go"
+)
+
+const (
+	defaultLastOutputTTLSeconds = 3600             // how long the last outputs are kept
+	maxCachedOutputBytes        = 32 * 1024 * 1024 // max total size of cached outputs
+)
+
+// CachedOutput struct for a cached output
+type CachedOutput struct {
+	Bytes    []byte
+	Mime     string
+	Caption  string
+	CachedAt time.Time
+}
+
+// OutputCache struct for cached outputs
+type OutputCache struct {
+	outputs    map[string]CachedOutput
+	size       int
+	ttlSeconds *int // (points to the configured value)
+	sync.Mutex
+}
+
+// the last outputs, keyed by user ids
+var lastOutputs = OutputCache{
+	outputs:    map[string]CachedOutput{},
+	ttlSeconds: &lastOutputTTLSeconds,
+}
+
+// remove the output with given key
+//
+// (should be called while holding the lock)
+func (c *OutputCache) remove(key string) {
+	if output, exists := c.outputs[key]; exists {
+		c.size -= len(output.Bytes)
+		delete(c.outputs, key)
+	}
+}
+
+// remove expired outputs
+//
+// (should be called while holding the lock)
+func (c *OutputCache) evictExpired(now time.Time) {
+	for key, output := range c.outputs {
+		if now.Sub(output.CachedAt) > time.Duration(*c.ttlSeconds)*time.Second {
+			c.remove(key)
+		}
+	}
+}
+
+// remove the oldest output
+//
+// (should be called while holding the lock)
+func (c *OutputCache) evictOldest() {
+	oldest := ""
+	for key, output := range c.outputs {
+		if len(oldest) <= 0 || output.CachedAt.Before(c.outputs[oldest].CachedAt) {
+			oldest = key
+		}
+	}
+	c.remove(oldest)
+}
+
+// store the output with given key
+func (c *OutputCache) store(key string, bytes []byte, mime, caption string) {
+	c.Lock()
+	defer c.Unlock()
+
+	now := time.Now()
+	c.remove(key)
+	c.evictExpired(now)
+
+	if len(bytes) > maxCachedOutputBytes {
+		log.Printf("Output of %s is too large to be cached (%d bytes)", key, len(bytes))
+		return
+	}
+	for c.size+len(bytes) > maxCachedOutputBytes {
+		c.evictOldest()
+	}
+
+	c.outputs[key] = CachedOutput{
+		Bytes:    bytes,
+		Mime:     mime,
+		Caption:  caption,
+		CachedAt: now,
+	}
+	c.size += len(bytes)
+}
+
+// get the output with given key
+func (c *OutputCache) get(key string) (output CachedOutput, exists bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.evictExpired(time.Now())
+
+	output, exists = c.outputs[key]
+	return output, exists
+}
+
+// remove all outputs, and return the number of removed ones
+func (c *OutputCache) flush() int {
+	c.Lock()
+	defer c.Unlock()
+
+	num := len(c.outputs)
+	c.outputs = map[string]CachedOutput{}
+	c.size = 0
+
+	return num
+}
+
+// send given cached output again
+func resendOutput(b BotClient, chatID interface{}, output CachedOutput, options map[string]interface{}) bool {
+	var sent bot.APIResponseMessage
+	if isPhoto(output.Mime) {
+		b.SendChatAction(chatID, bot.ChatActionUploadPhoto)
+
+		sent = b.SendPhoto(chatID, bot.InputFileFromBytes(output.Bytes), optionsWithCaption(options, output.Caption))
+	} else if strings.HasPrefix(output.Mime, "video") {
+		b.SendChatAction(chatID, bot.ChatActionUploadVideo)
+
+		sent = b.SendVideo(chatID, bot.InputFileFromBytes(output.Bytes), optionsWithCaption(options, output.Caption))
+	} else if message := appendLine(string(output.Bytes), output.Caption); isPlainText(output.Mime) && utf8.Valid(output.Bytes) && len(splitMessage(message, messageChunkBytes)) <= maxMessageChunks {
+		if len(strings.TrimSpace(message)) <= 0 {
+			message = messageNoOutput
+		}
+		for _, chunk := range splitMessage(message, messageChunkBytes) {
+			if sent = b.SendMessage(chatID, chunk, options); !sent.Ok {
+				break
+			}
+		}
+	} else {
+		b.SendChatAction(chatID, bot.ChatActionUploadDocument)
+
+		sent = sendDocumentWithFilename(b, chatID, output.Bytes, documentFilename(output.Mime), optionsWithCaption(options, output.Caption))
+	}
+
+	if !sent.Ok {
+		log.Printf("*** Failed to resend the last output: %s", *sent.Description)
+	}
+	return sent.Ok
+}
@@ -0,0 +1,100 @@
+// log formats (text or json)
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+
+	errorLogPrefix = "*** " // prefix of error logs
+)
+
+// JSONLogWriter struct for writing lines from the log package as json objects
+type JSONLogWriter struct {
+	Out io.Writer
+}
+
+// Write writes a log line as a json object
+func (w JSONLogWriter) Write(p []byte) (n int, err error) {
+	writeJSONLog(w.Out, strings.TrimRight(string(p), "\n"), nil)
+
+	return len(p), nil
+}
+
+// write a json log with given message and fields
+func writeJSONLog(out io.Writer, message string, fields map[string]interface{}) {
+	entry := map[string]interface{}{}
+	for k, v := range fields {
+		entry[k] = v
+	}
+
+	entry["time"] = time.Now().Format(time.RFC3339Nano)
+	if strings.HasPrefix(message, errorLogPrefix) {
+		entry["level"] = "error"
+		entry["message"] = strings.TrimPrefix(message, errorLogPrefix)
+	} else {
+		entry["level"] = "info"
+		entry["message"] = message
+	}
+
+	bytes, err := json.Marshal(entry)
+	if err != nil {
+		bytes = []byte(fmt.Sprintf(`{"level":"error","message":"failed to marshal log: %s"}`, err))
+	}
+	out.Write(append(bytes, '\n'))
+}
+
+// fields of given execute request for structured logs, merged with given ones
+func requestFields(request ExecuteRequest, fields map[string]interface{}) map[string]interface{} {
+	merged := map[string]interface{}{
+		"user_id": request.UserID,
+		"chat_id": request.ChatID,
+		"script":  valueOrDefault(request.ScriptName, request.ScriptPath),
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return merged
+}
+
+// set up the log package for given format
+func setupLogFormat(format string) {
+	if format == logFormatJSON {
+		log.SetFlags(0)
+		log.SetOutput(JSONLogWriter{Out: os.Stderr})
+	}
+}
+
+// log a message with structured fields (eg. user_id, command, duration_ms, result)
+//
+// (fields are kept as json fields in json format, or appended as key=value pairs in text format)
+func logEvent(message string, fields map[string]interface{}) {
+	if logFormat == logFormatJSON {
+		writeJSONLog(os.Stderr, message, fields)
+		return
+	}
+
+	keys := []string{}
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := []string{}
+	for _, k := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s=%v", k, fields[k]))
+	}
+
+	log.Printf("%s (%s)", message, strings.Join(pairs, ", "))
+}
@@ -3,17 +3,30 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
-	"net/http"
+	"os"
 	"os/exec"
 	"path"
 	"path/filepath"
+	"regexp"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
 
 	bot "github.com/meinside/telegram-bot-go"
 )
@@ -24,115 +37,744 @@ type Status int16
 // Status constants
 const (
 	StatusWaiting Status = iota
+	StatusAwaitingReason
+	StatusCollectingParameters
+	StatusAwaitingScriptChoice // next message is the name of a script (and its arguments)
+	StatusAwaitingArgs         // next message is the arguments of the chosen script
 )
 
 const (
 	defaultMonitorIntervalSeconds = 5 // for monitoring
+	minMonitorIntervalSeconds     = 1
+	maxMonitorIntervalSeconds     = 600
+
+	telegramAPIBaseURL = "https://api.telegram.org/bot"
+
+	// default reactions
+	defaultReactionReceived  = "👀"
+	defaultReactionSucceeded = "✅"
+	defaultReactionFailed    = "❌"
 
 	numQueue = 4 // size of queue
 
+	defaultScriptsPerPage = 5 // number of script buttons on a page
+
+	defaultCameraResetTimeoutSeconds = 30
+
+	defaultTeardownTimeoutSeconds = 10 // (teardown runs while holding the device's lock)
+
+	defaultMaxClipSeconds = 30
+
+	defaultReasonTimeoutSeconds = 60
+
+	defaultMaxConcurrent = 1 // number of executions at the same time
+
+	defaultSnapTimeoutSeconds = 10 // timeout of /snap
+
+	defaultArgumentPattern = `^[A-Za-z0-9_.,:=+-]+$` // allowlist of arguments passed to scripts
+
+	chatActionIntervalSeconds = 4 // chat actions last for 5 seconds or less
+
+	scriptWaitDelaySeconds = 5 // wait for outputs of killed scripts' children before closing them
+
+	// fallbacks for binary outputs which are neither media nor valid text
+	binaryFallbackDocument = "document"
+	binaryFallbackHex      = "hex"
+	binaryFallbackBase64   = "base64"
+	binaryFallbackError    = "error"
+
+	// verbosity of error messages for users
+	errorVerbosityFull    = "full"
+	errorVerbositySummary = "summary"
+	errorVerbosityGeneric = "generic"
+
+	binarySummaryNumBytes = 256 // number of bytes to be included in hex/base64 summaries
+
+	// prefixes of callback data
+	callbackPrefixPage    = "page:"
+	callbackPrefixScript  = "script:"
+	callbackPrefixValue   = "value:"
+	callbackPrefixConfirm = "confirm:"
+	callbackPrefixDeny    = "deny:"
+
 	// commands
-	commandStart    = "/start"
-	commandExecute  = "/execute"
-	commandShowCode = "/showcode"
+	commandStart       = "/start"
+	commandHelp        = "/help"
+	commandExecute     = "/execute"
+	commandScripts     = "/scripts"
+	commandCancel      = "/cancel"
+	commandStop        = "/stop"
+	commandAnnotate    = "/annotate"
+	commandClip        = "/clip"
+	commandShowCode    = "/showcode"
+	commandLast        = "/last"
+	commandFlushCache  = "/flushcache" // admin only
+	commandHistory     = "/history"
+	commandFormat      = "/format"
+	commandSchedules   = "/schedules"
+	commandWhoAmI      = "/whoami"
+	commandSnap        = "/snap"
+	commandPipeline    = "/pipeline"
+	commandCancelQueue = "/cancelqueue"
+	commandPing        = "/ping"
+	commandBusy        = "/busy"
+	commandVersion     = "/version"
+	commandConfig      = "/config"      // admin only
+	commandCamReset    = "/camreset"    // admin only
+	commandInterval    = "/interval"    // admin only
+	commandMaintenance = "/maintenance" // admin only
+	commandDisk        = "/disk"
+	commandStatus      = "/status"
+	commandPreview     = "/preview" // admin only
+	commandReload      = "/reload"  // admin only
+
+	// arguments of commands
+	argumentAll  = "all" // eg. /stop all
+	argumentNone = "-"   // no arguments, when asked for them
+
+	// formats of text replies
+	formatMarkdown = "markdown"
+	formatHTML     = "html"
+	formatNone     = "none"
 
 	// messages
-	messageDefault        = "Input your command:"
-	messageUnknownCommand = "Unknown command."
-	messageErrorFormat    = "Error: %s"
+	messageDefault              = "Input your command:"
+	messageUnknownCommand       = "Unknown command."
+	messageDidYouMeanFormat     = "Did you mean %s?"
+	messageErrorFormat          = "Error: %s"
+	messageGenericError         = "Something went wrong."
+	messageExecutionFailed      = "Execution failed."
+	messageErrorReportFormat    = "Script failed: %s\n\nArgs: %s\nUser: %s\nExit code: %d\nError: %s\n\nStderr:\n%s"
+	messageCooldownFormat       = "Please wait %ds before running again."
+	messageChatCooldownFormat   = "Please wait %ds before running again in this chat."
+	messageRateLimitedFormat    = "Rate limit exceeded, wait %d seconds."
+	messageAdminOnly            = "Only admins can do this."
+	messageAlreadyPending       = "You already have a request pending."
+	messageChooseScript         = "Choose a script to execute (%d/%d):"
+	messageNoSuchScript         = "No such script."
+	messageAskScriptChoice      = "Send the name of a script (with its arguments), or /cancel."
+	messageAskArgsFormat        = "Send arguments for '%s' ('" + argumentNone + "' for none), or /cancel."
+	messageScriptNotPermitted   = "You are not permitted to run this script."
+	messageNoScripts            = "No scripts are configured."
+	messageTimedOutFormat       = "Script timed out after %d seconds."
+	messageOutputTooLargeFormat = "Output too large, truncated at %s."
+	messageInvalidArgs          = "Arguments not allowed: %s"
+	messageScriptsFormat        = "Available scripts:\n\n%s"
+	messageExecuting            = "Executing: %s"
+	messageChooseValue          = "%s: choose the value of '%s' (%d/%d):"
+	messageEnterValue           = "%s: enter the value of '%s' (%d/%d), one of: %s (or /cancel)"
+	messageInvalidValue         = "Not an allowed value."
+	messageAllowedValuesFormat  = "Allowed values of --%s: %s"
+	messageNotConfiguring       = "No script is being configured."
+	messageAnnotateUsage        = "Usage: /annotate <text>"
+	messageNoDefaultScript      = "Default script is not configured."
+	messageProgressFormat       = "Progress: %d%%"
+	messageDeviceBusy           = "Camera is busy, try again later."
+	messageDeviceFreeFormat     = "%s: free"
+	messageDeviceBusyFormat     = "%s: busy"
+	messageDeviceInUseFormat    = "%s: running '%s' for %s"
+	messageDeviceUserFormat     = " (by %s)"
+	messageVersionFormat        = "Version: %s\nCommit: %s\nBuilt at: %s\nGo: %s"
+	messageWarmingUpFormat      = "Camera warming up, starting in %d second(s)."
+	messagePingFormat           = "Pong!\n\nRound-trip to Telegram: %dms\nDelay of the update: %s"
+	messagePingFailedFormat     = "Failed to reach Telegram (%dms): %s"
+	messageUnauthorizedFormat   = "Access attempt by a user who is not allowed:\n\nID: %d\nUsername: %s\nText: %s"
+	messageInputPhotoFailed     = "Failed to download the photo."
+	messagePipelineUsage        = "Usage: /pipeline <script>|<script>|..."
+	messageNotInPipeline        = "Scripts which require a reason or confirmation cannot be run in a pipeline: %s"
+	messageDiskFormat           = "Free disk space: %s (%s)"
+	messageNotEnoughDiskSpace   = "Not enough disk space: %s free on %s (min: %d MB)"
+	messageNoClipScript         = "Clip script is not configured."
+	messageClipUsage            = "Usage: /clip <seconds> (max: %d)"
+	messageClipTooLong          = "Clip of %d seconds is too long (max: %d seconds)."
+	messageAskReason            = "Please tell me the reason for executing '%s' (or /cancel), within %d seconds:"
+	messageCancelled            = "Cancelled."
+	messageConfirmFormat        = "Do you really want to execute '%s'? (expires in %d seconds)"
+	messageConfirmationExpired  = "Confirmation expired, execute it again."
+	messageNotYourConfirmation  = "Only the user who requested it can confirm it."
+	messageNothingToCancel      = "Nothing to cancel."
+	messageNothingRunning       = "Nothing is running."
+	messageNotYourExecution     = "Only the user who started it can stop it (admins can stop it with /stop all)."
+	messageStopping             = "Stopping the running execution."
+	messageQueueCancelledFormat = "%d queued request(s) cancelled."
+	messageNothingQueued        = "You have no queued requests."
+	messageQueueCancelled       = "Your queued requests are cancelled."
+	messageQueuePositionFormat  = "You are #%d in queue."
+	messageQueueFull            = "Queue full, try again later."
+	messageShuttingDown         = "The bot is shutting down, try again later."
+	messageMaintenance          = "Bot is in maintenance mode, try again later."
+	messageMaintenanceFormat    = "Maintenance mode: %s"
+	messageMaintenanceUsage     = "Usage: /maintenance on|off"
+	messageNoOutput             = "(no output)"
+	messageNoLastOutput         = "No recent output to resend."
+	messageCachedFormat         = "(cached %d seconds ago)"
+	messageCacheFlushedFormat   = "%d cached result(s) removed."
+	messageNoHistory            = "No executions in the history."
+	messageWhoAmIFormat         = "ID: %d\nUsername: %s\nAllowed: %s"
+	messageNoSchedules          = "No schedules are configured."
+	messageSchedulesFormat      = "Schedules:\n\n%s"
+	messageFormatUsage          = "Usage: /format markdown|html|none"
+	messageFormatFormat         = "Formatting of text replies: %s"
+	messageStatusFormat         = "Uptime: %s\nQueued: %d\nRunning: %d\nLast run: %s"
+	messageExecutionCancelled   = "Execution cancelled by user."
+	messagePreviewFormat        = "Script: %s\n\nCommand: %s\nWorking directory: %s\nEnvironment: %s\nTimeout: %s\nResource limits: %s"
+	messageReloadedFormat       = "Reloaded config: %d user(s) added, %d user(s) removed."
+	messageNoCameraReset        = "Camera reset command is not configured."
+	messageIntervalFormat       = "Monitor interval: %d second(s)"
+	messageInvalidInterval      = "Usage: /interval <seconds> (%d ~ %d)"
+	messageHelpFormat           = "Commands:\n\n%s\n\nMonitor interval: %d second(s)\nScripts: %d\nVerbose: %t"
+
+	// inline buttons
+	buttonPrevPage = "« Prev"
+	buttonNextPage = "Next »"
+	buttonConfirm  = "Yes"
+	buttonDeny     = "No"
+
+	redactedString = "<REDACTED>"
 )
 
 // Session struct
 type Session struct {
-	UserID        string
-	CurrentStatus Status
+	UserID         string
+	CurrentStatus  Status
+	LastExecutedAt time.Time
+	PendingCount   int // number of queued or running requests
+
+	// script being configured with parameters, and the values chosen so far
+	ConfiguringScript string
+	ConfiguringValues []string
+
+	// request waiting for a reason from the user
+	PendingRequest    *ExecuteRequest
+	ReasonRequestedAt time.Time
+
+	// last selected script and its arguments, for re-running it with a bare /execute
+	LastScript string
+	LastArgs   []string
+
+	// queued requests before this time are cancelled
+	CancelledBefore time.Time
+
+	// formatting of text replies (empty for none)
+	ParseMode bot.ParseMode
 }
 
 // SessionPool struct is a session pool for storing individual statuses
 type SessionPool struct {
-	Sessions map[string]Session
+	Sessions           map[string]Session
+	ChatLastExecutedAt map[int64]time.Time // for per-chat cooldown
+	RateLimits         map[string]TokenBucket
 	sync.Mutex
 }
 
-// for making sure the camera is not used simultaneously
-var executeLock sync.Mutex
+// error for scripts killed for timeout
+var errScriptTimedOut = errors.New("script timed out")
 
 // ExecuteRequest struct
 type ExecuteRequest struct {
+	UserID         string
 	ChatID         interface{}
+	MessageID      int
+	ScriptName     string
+	ScriptPath     string
+	Args           []string
+	Annotation     string // text to be burned onto the resulting image
+	Reason         string // reason given by the user
+	RequestedAt    time.Time
+	Device         string // device (camera) used by the script
+	Teardown       string // teardown command of the script, overriding the global one
+	ClipSeconds    int    // duration of a clip being recorded
 	MessageOptions map[string]interface{}
+	Reacted        bool     // whether the triggering message was reacted to on receipt
+	Immediate      bool     // run right away without being queued (fails when the device is busy)
+	TimeoutSeconds int      // overrides the timeout of the script
+	Pipeline       []string // names of scripts run in sequence, instead of a single script
+	InputFileID    string   // id of an uploaded photo, passed to the script as its last argument
+}
+
+// BotClient interface for the bot API methods used by handlers
+//
+// (implemented by *APIClient, and can be replaced with a mock in tests)
+type BotClient interface {
+	SendMessage(chatID bot.ChatID, text string, options bot.OptionsSendMessage) bot.APIResponseMessage
+	SendPhoto(chatID bot.ChatID, photo bot.InputFile, options bot.OptionsSendPhoto) bot.APIResponseMessage
+	SendVideo(chatID bot.ChatID, video bot.InputFile, options bot.OptionsSendVideo) bot.APIResponseMessage
+	SendAnimation(chatID bot.ChatID, animation bot.InputFile, options bot.OptionsSendAnimation) bot.APIResponseMessage
+	SendDocument(chatID bot.ChatID, document bot.InputFile, options bot.OptionsSendDocument) bot.APIResponseMessage
+	SendMediaGroup(chatID bot.ChatID, media []bot.InputMedia, options bot.OptionsSendMediaGroup) bot.APIResponseMessages
+	SendChatAction(chatID bot.ChatID, action bot.ChatAction) bot.APIResponseBool
+	EditMessageText(text string, options bot.OptionsEditMessageText) bot.APIResponseMessageOrBool
+	DeleteMessage(chatID bot.ChatID, messageID int) bot.APIResponseBool
+	GetFile(fileID string) bot.APIResponseFile
+	GetMe() bot.APIResponseUser
+	GetFileURL(file bot.File) string
+	AnswerCallbackQuery(callbackQueryID string, options bot.OptionsAnswerCallbackQuery) bot.APIResponseBool
+	SetMessageReaction(chatID bot.ChatID, messageID int, emoji string) bot.APIResponseBool
 }
 
 // variables
 var apiToken string
 var monitorInterval int
+var monitorIntervalLock sync.Mutex
 var isVerbose bool
 var allowedIds []string
-var scriptPath string
+var allowedUserIDs []int64
+var adminIds []string
+var scriptPath string // (changed by reloading, so should be accessed while holding pool's lock)
+var scripts map[string]Script
+var scriptsPerPage int
+var cooldownSeconds int
+var chatCooldownSeconds int
+var adminsExemptFromCooldown bool
+var maxPendingPerUser int
+var teardownCommand string
+var teardownTimeoutSeconds int
+var cameraResetCommand string
+var cameraResetTimeoutSeconds int
+var cameraCooldownSeconds int
+var disableNotification bool
+var showDuration bool
+var errorVerbosity string
+var errorChatID int64
+var captionTemplate string
+var webhook *WebhookConfig
+var timeoutSeconds int
+var inlineKeyboardOnly bool
+var shutdownGraceSeconds int
+var permissions map[string][]string
+var scriptEnv map[string]string
+var healthPort int
+var snapTimeoutSeconds int
+var maxOutputBytes int
+var logFormat string
+var maxPerMinute int
+var sessionsPath string
+var offsetPath string
+var historyPath string
+var maxConcurrent int
+var argumentPattern *regexp.Regexp
+var groupSendIntervalMillis int
+var sendRetries int
+var lastOutputTTLSeconds int
+var resultCacheTTLSeconds int
+var auditLogPath string
+var reasonTimeoutSeconds int
+var confirmationTimeoutSeconds int
+var clipScriptPath string
+var maxClipSeconds int
+var tempDir string
+var diskCheckPath string
+var minFreeDiskMB int
+var binaryOutputFallback string
+var useReactions bool
+var resultWebhook string
+var resultWebhookRetries int
+var resultWebhookBackoffSeconds int
+var adminChatIDs []int64
+var archiveDir string
+var archiveMaxFiles int
+var archiveMaxAgeDays int
+var commandPrefix string
+var botConfigPaths []string
+var unauthorizedAlertIntervalSeconds int
+var schedules []Schedule
+var digestTime string
+var digestChatID int64
+var reactionReceived, reactionSucceeded, reactionFailed string
 var pool SessionPool
+var currentConfig Config
+var currentConfigLock sync.Mutex
 var executeChannel chan ExecuteRequest
+var queueLength int32 // number of requests waiting in executeChannel
 
-// keyboards
-var allKeyboards = [][]bot.KeyboardButton{
-	bot.NewKeyboardButtons(commandExecute),
-	bot.NewKeyboardButtons(commandShowCode),
+// keyboards (can be replaced with `keyboard` in config)
+var defaultKeyboard = [][]string{
+	{commandExecute},
+	{commandShowCode},
 }
+var allKeyboards [][]bot.KeyboardButton
 
 const (
 	// constants for config
 	configFilename = "config.json"
 )
 
+// WebhookConfig struct for receiving updates with webhook instead of polling
+type WebhookConfig struct {
+	Host         string `json:"host"` // external host name of this bot
+	Port         int    `json:"port"` // port to listen on (and to be registered)
+	CertFilepath string `json:"cert_filepath"`
+	KeyFilepath  string `json:"key_filepath"`
+}
+
 // Config struct for config file
 type Config struct {
-	APIToken        string   `json:"api_token"`
-	AllowedIds      []string `json:"allowed_ids"`
-	MonitorInterval int      `json:"monitor_interval"`
-	ScriptPath      string   `json:"script_path"`
-	IsVerbose       bool     `json:"is_verbose"`
+	APIToken             string              `json:"api_token"`
+	AllowedIds           []string            `json:"allowed_ids"`
+	AllowedIdsFile       string              `json:"allowed_ids_file,omitempty"` // file with allowed ids, one per line
+	AllowedUserIDs       []int64             `json:"allowed_user_ids,omitempty"` // numeric user ids (preferred over usernames)
+	AdminIds             []string            `json:"admin_ids,omitempty"`
+	Permissions          map[string][]string `json:"permissions,omitempty"`    // user id => names of scripts the user can run (all scripts when not given)
+	AdminChatIDs         []int64             `json:"admin_chat_ids,omitempty"` // chats for notifying admins
+	MonitorInterval      int                 `json:"monitor_interval"`
+	Webhook              *WebhookConfig      `json:"webhook,omitempty"`     // receive updates with webhook (polling when not given)
+	Bots                 []string            `json:"bots,omitempty"`        // config files of other bots, run along with this one
+	HealthPort           int                 `json:"health_port,omitempty"` // port of the health check endpoint (/healthz), disabled when not given
+	ScriptPath           string              `json:"script_path"`
+	Scripts              map[string]Script   `json:"scripts,omitempty"`    // name => path (or script object)
+	ScriptEnv            map[string]string   `json:"script_env,omitempty"` // environment variables for all scripts, ${VAR} is replaced with the bot's
+	ScriptsPerPage       int                 `json:"scripts_per_page,omitempty"`
+	TimeoutSeconds       int                 `json:"timeout_seconds,omitempty"`        // timeout of script executions (0 = no timeout)
+	MaxOutputBytes       int                 `json:"max_output_bytes,omitempty"`       // scripts printing more than this will be killed
+	SnapTimeoutSeconds   int                 `json:"snap_timeout_seconds,omitempty"`   // timeout of /snap
+	MaxConcurrent        int                 `json:"max_concurrent,omitempty"`         // number of scripts running at the same time (on different devices)
+	ShutdownGraceSeconds int                 `json:"shutdown_grace_seconds,omitempty"` // how long running scripts are waited for on shutdown
+	ArgumentPattern      string              `json:"argument_pattern,omitempty"`       // regexp for allowed arguments of /execute
+	Keyboard             [][]string          `json:"keyboard,omitempty"`               // rows of commands or script names
+	CommandPrefix        string              `json:"command_prefix,omitempty"`         // prefix of commands, eg. "cv" for /cv_execute
+	Roles                map[string]Role     `json:"roles,omitempty"`                  // "admin" or "guest" => welcome message and keyboard
+	InlineKeyboardOnly   bool                `json:"inline_keyboard_only,omitempty"`   // remove the reply keyboard, and choose scripts with inline buttons only
+
+	ClipScriptPath           string `json:"clip_script_path,omitempty"` // script for recording clips (receives: --duration SECONDS)
+	MaxClipSeconds           int    `json:"max_clip_seconds,omitempty"`
+	CooldownSeconds          int    `json:"cooldown_seconds"`
+	ChatCooldownSeconds      int    `json:"chat_cooldown_seconds"`
+	AdminsExemptFromCooldown bool   `json:"admins_exempt_from_cooldown"`
+	MaxPendingPerUser        int    `json:"max_pending_per_user"`     // 0 for unlimited
+	MaxPerMinute             int    `json:"max_per_minute,omitempty"` // executions per user per minute (0 = unlimited)
+	TeardownCommand          string `json:"teardown_command,omitempty"`
+	TeardownTimeoutSeconds   int    `json:"teardown_timeout_seconds,omitempty"`
+
+	CameraResetCommand        string `json:"camera_reset_command,omitempty"`
+	CameraResetTimeoutSeconds int    `json:"camera_reset_timeout_seconds,omitempty"`
+	CameraCooldownSeconds     int    `json:"camera_cooldown_seconds,omitempty"` // wait between accesses to the same camera, for it to reinitialize
+
+	DisableNotification     bool   `json:"disable_notification"`                 // send results of scheduled executions silently
+	GroupSendIntervalMillis int    `json:"group_send_interval_millis,omitempty"` // minimum interval between sends to the same group chat
+	LastOutputTTLSeconds    int    `json:"last_output_ttl_seconds,omitempty"`    // how long the last outputs are kept for /last
+	ResultCacheTTLSeconds   int    `json:"result_cache_ttl_seconds,omitempty"`   // how long results are served to the same requests without running scripts (0 = not cached)
+	SendRetries             int    `json:"send_retries,omitempty"`               // retries for transient send failures, negative for no retry
+	ShowDuration            bool   `json:"show_duration"`                        // show how long executions took
+	CaptionTemplate         string `json:"caption_template,omitempty"`           // default template for captions, rendered with the json after #META:
+	ErrorVerbosity          string `json:"error_verbosity,omitempty"`            // "full" (default), "summary", or "generic"
+	ErrorChatID             int64  `json:"error_chat_id,omitempty"`              // chat for detailed errors of failed scripts (users only get brief messages)
+
+	AuditLogPath         string `json:"audit_log_path,omitempty"`         // file for audit logs (or the log when not given)
+	HistoryPath          string `json:"history_path,omitempty"`           // file for persisting the history of executions (default: history.json next to config.json)
+	HistorySize          int    `json:"history_size,omitempty"`           // max number of executions kept in the history
+	SessionsPath         string `json:"sessions_path,omitempty"`          // file for persisting sessions (default: sessions.json next to config.json)
+	OffsetPath           string `json:"offset_path,omitempty"`            // file for persisting the offset of updates (default: offset next to config.json)
+	ReasonTimeoutSeconds int    `json:"reason_timeout_seconds,omitempty"` // timeout of prompts for reasons
+
+	ConfirmationTimeoutSeconds int `json:"confirmation_timeout_seconds,omitempty"` // timeout of confirmations of scripts with `requires_confirmation`
+
+	ArchiveDir        string `json:"archive_dir,omitempty"`          // directory for saving outputs (not saved when not given)
+	ArchiveMaxFiles   int    `json:"archive_max_files,omitempty"`    // max number of archived files (0 = unlimited)
+	ArchiveMaxAgeDays int    `json:"archive_max_age_days,omitempty"` // archived files older than this are removed (0 = unlimited)
+
+	TempDir              string `json:"temp_dir,omitempty"`               // directory for temporary files (eg. on a tmpfs), defaults to the system's
+	DiskCheckPath        string `json:"disk_check_path,omitempty"`        // defaults to the temp directory
+	MinFreeDiskMB        int    `json:"min_free_disk_mb,omitempty"`       // abort capturing when free space is below this
+	BinaryOutputFallback string `json:"binary_output_fallback,omitempty"` // "document" (default), "hex", "base64", or "error"
+
+	UseReactions      bool   `json:"use_reactions"`
+	ReactionReceived  string `json:"reaction_received,omitempty"`
+	ReactionSucceeded string `json:"reaction_succeeded,omitempty"`
+	ReactionFailed    string `json:"reaction_failed,omitempty"`
+
+	DigestTime   string `json:"digest_time,omitempty"` // daily, in "HH:MM" format
+	DigestChatID int64  `json:"digest_chat_id,omitempty"`
+
+	ResultWebhook               string `json:"result_webhook,omitempty"`         // url for forwarding results
+	ResultWebhookRetries        int    `json:"result_webhook_retries,omitempty"` // negative for no retry
+	ResultWebhookBackoffSeconds int    `json:"result_webhook_backoff_seconds,omitempty"`
+
+	Schedules                  []Schedule `json:"schedules,omitempty"`
+	MinScheduleIntervalSeconds int        `json:"min_schedule_interval_seconds,omitempty"`
+	MaxSchedules               int        `json:"max_schedules,omitempty"`
+
+	UnauthorizedAlertIntervalSeconds int `json:"unauthorized_alert_interval_seconds,omitempty"` // min interval between alerts of access attempts by the same user
+
+	IsVerbose bool   `json:"is_verbose"`
+	LogFormat string `json:"log_format,omitempty"` // "text" (default) or "json"
 }
 
+// path of the config file, given with `-config` flag
+var configPath = flag.String("config", "", "path of the config file (default: next to the source files)")
+
 // Read config
+//
+// (from the path given with `-config` flag, or next to the source files when it is not given)
+//
+// (flags should be parsed before calling this)
 func getConfig() (config Config, err error) {
+	return loadConfig(configFilePath())
+}
+
+// path of the config file in use
+func configFilePath() string {
+	if len(*configPath) > 0 {
+		return *configPath
+	}
+
+	return findConfigFile(configDir())
+}
+
+// directory of the config file
+//
+// (files like sessions and history are saved here by default)
+func configDir() string {
+	if len(*configPath) > 0 {
+		return filepath.Dir(*configPath)
+	}
+
 	_, filename, _, _ := runtime.Caller(0) // = __FILE__
 
-	file, err := ioutil.ReadFile(filepath.Join(path.Dir(filename), configFilename))
-	if err == nil {
-		var conf Config
+	return path.Dir(filename)
+}
 
-		err := json.Unmarshal(file, &conf)
-		if err == nil {
-			return conf, nil
-		}
+// return given value, or default value if it is empty
+func valueOrDefault(value, defaultValue string) string {
+	if len(value) > 0 {
+		return value
+	}
+	return defaultValue
+}
 
-		return Config{}, err
+// return given value, or default value if it is not positive
+func intOrDefault(value, defaultValue int) int {
+	if value > 0 {
+		return value
+	}
+	return defaultValue
+}
+
+// read ids from given file, one per line
+//
+// (blank lines and lines starting with '#' are ignored)
+func readIdsFile(filename string) (ids []string, err error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) <= 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		ids = append(ids, line)
 	}
 
-	return Config{}, err
+	return ids, scanner.Err()
 }
 
-// read code from the python script
-func readCode() string {
-	bytes, err := ioutil.ReadFile(scriptPath)
-	if err == nil {
-		return string(bytes)
+// merge given ids without duplicates
+func mergeIds(ids, others []string) []string {
+	merged := []string{}
+	exists := map[string]bool{}
+	for _, id := range append(append([]string{}, ids...), others...) {
+		if !exists[id] {
+			merged = append(merged, id)
+			exists[id] = true
+		}
 	}
+	return merged
+}
+
+// read code of given script
+//
+// (empty name for the default script, and should be called while holding pool's lock)
+func readCode(name string) (code []byte, filename string, err error) {
+	path := scriptPath
+	if len(name) > 0 {
+		script, exists := scripts[name]
+		if !exists {
+			return nil, "", fmt.Errorf("no such script: %s", name)
+		}
+		path = script.Path
+	}
+	if len(path) <= 0 {
+		return nil, "", fmt.Errorf("no default script")
+	}
+
+	code, err = ioutil.ReadFile(path)
 
-	return fmt.Sprintf(messageErrorFormat, err)
+	return code, filepath.Base(path), err
 }
 
-// initialization
-func init() {
+// initialization with the config file
+//
+// (called from main, after flags are parsed, so that tests can run without a config file)
+func setup() {
 	// read variables from config file
 	if config, err := getConfig(); err == nil {
 		apiToken = config.APIToken
 		allowedIds = config.AllowedIds
+		if len(config.AllowedIdsFile) > 0 {
+			if ids, err := readIdsFile(config.AllowedIdsFile); err == nil {
+				allowedIds = mergeIds(allowedIds, ids)
+			} else {
+				panic(err.Error())
+			}
+		}
+		allowedUserIDs = config.AllowedUserIDs
+		adminIds = config.AdminIds
+		adminChatIDs = config.AdminChatIDs
+		if !isChildBot() {
+			botConfigPaths = config.Bots
+		}
+		unauthorizedAlertIntervalSeconds = intOrDefault(config.UnauthorizedAlertIntervalSeconds, defaultUnauthorizedAlertIntervalSeconds)
 		monitorInterval = config.MonitorInterval
 		if monitorInterval <= 0 {
 			monitorInterval = defaultMonitorIntervalSeconds
 		}
 		scriptPath = config.ScriptPath
+		permissions = config.Permissions
+		scripts = config.Scripts
+		if err := validateScripts(scripts); err != nil {
+			panic(err.Error())
+		}
+		healthPort = config.HealthPort
+		if healthPort < 0 || healthPort > 65535 {
+			panic(fmt.Sprintf("invalid health_port: %d", healthPort))
+		}
+		scriptEnv = config.ScriptEnv
+		if err := validateEnv(scriptEnv); err != nil {
+			panic(fmt.Sprintf("invalid script_env: %s", err))
+		}
+		commandPrefix = strings.TrimSuffix(config.CommandPrefix, commandPrefixSeparator) // eg. "cv_" => "cv"
+		if len(commandPrefix) > 0 && !commandPrefixPattern.MatchString(commandPrefix) {
+			panic(fmt.Sprintf("invalid command_prefix: '%s' (only lowercase letters and digits, separated with underscores, are allowed)", config.CommandPrefix))
+		}
+		keyboard := defaultKeyboard
+		if len(config.Keyboard) > 0 {
+			keyboard = config.Keyboard
+		}
+		if allKeyboards, err = buildKeyboards(keyboard, scripts); err != nil {
+			panic(err.Error())
+		}
+		roles = config.Roles
+		if roleKeyboards, err = buildRoleKeyboards(roles, scripts); err != nil {
+			panic(err.Error())
+		}
+		inlineKeyboardOnly = config.InlineKeyboardOnly
+		scriptsPerPage = config.ScriptsPerPage
+		if scriptsPerPage <= 0 {
+			scriptsPerPage = defaultScriptsPerPage
+		}
+		cooldownSeconds = config.CooldownSeconds
+		chatCooldownSeconds = config.ChatCooldownSeconds
+		adminsExemptFromCooldown = config.AdminsExemptFromCooldown
+		maxPendingPerUser = config.MaxPendingPerUser
+		maxPerMinute = config.MaxPerMinute
+		teardownCommand = config.TeardownCommand
+		teardownTimeoutSeconds = config.TeardownTimeoutSeconds
+		if teardownTimeoutSeconds <= 0 {
+			teardownTimeoutSeconds = defaultTeardownTimeoutSeconds
+		}
+		cameraResetCommand = config.CameraResetCommand
+		cameraResetTimeoutSeconds = config.CameraResetTimeoutSeconds
+		if cameraResetTimeoutSeconds <= 0 {
+			cameraResetTimeoutSeconds = defaultCameraResetTimeoutSeconds
+		}
+		cameraCooldownSeconds = config.CameraCooldownSeconds
+		disableNotification = config.DisableNotification
+		showDuration = config.ShowDuration
+		auditLogPath = config.AuditLogPath
+		captionTemplate = config.CaptionTemplate
+		webhook = config.Webhook
+		if webhook != nil {
+			if len(webhook.Host) <= 0 || len(webhook.CertFilepath) <= 0 || len(webhook.KeyFilepath) <= 0 {
+				panic("webhook needs host, cert_filepath, and key_filepath")
+			}
+			if webhook.Port != 443 && webhook.Port != 80 && webhook.Port != 88 && webhook.Port != 8443 {
+				panic(fmt.Sprintf("webhook port not supported by Telegram: %d (443, 80, 88, or 8443)", webhook.Port))
+			}
+		}
+		timeoutSeconds = config.TimeoutSeconds
+		snapTimeoutSeconds = intOrDefault(config.SnapTimeoutSeconds, defaultSnapTimeoutSeconds)
+		maxOutputBytes = intOrDefault(config.MaxOutputBytes, defaultMaxOutputBytes)
+		shutdownGraceSeconds = intOrDefault(config.ShutdownGraceSeconds, defaultShutdownGraceSeconds)
+		maxConcurrent = intOrDefault(config.MaxConcurrent, defaultMaxConcurrent)
+		if argumentPattern, err = regexp.Compile(valueOrDefault(config.ArgumentPattern, defaultArgumentPattern)); err != nil {
+			panic(fmt.Sprintf("invalid argument pattern: %s", err))
+		}
+		groupSendIntervalMillis = intOrDefault(config.GroupSendIntervalMillis, defaultGroupSendIntervalMillis)
+		lastOutputTTLSeconds = intOrDefault(config.LastOutputTTLSeconds, defaultLastOutputTTLSeconds)
+		resultCacheTTLSeconds = config.ResultCacheTTLSeconds
+		sendRetries = config.SendRetries
+		if sendRetries < 0 {
+			sendRetries = 0
+		} else if sendRetries == 0 {
+			sendRetries = defaultSendRetries
+		}
+		if _, err := parseCaptionTemplate(captionTemplate); err != nil {
+			panic(fmt.Sprintf("invalid caption template: %s", err))
+		}
+		reasonTimeoutSeconds = intOrDefault(config.ReasonTimeoutSeconds, defaultReasonTimeoutSeconds)
+		confirmationTimeoutSeconds = intOrDefault(config.ConfirmationTimeoutSeconds, defaultConfirmationTimeoutSeconds)
+		errorVerbosity = valueOrDefault(config.ErrorVerbosity, errorVerbosityFull)
+		errorChatID = config.ErrorChatID
+		clipScriptPath = config.ClipScriptPath
+		if err := validateScriptPaths(scriptPath, clipScriptPath, scripts); err != nil {
+			panic(err.Error())
+		}
+		maxClipSeconds = intOrDefault(config.MaxClipSeconds, defaultMaxClipSeconds)
+		archiveDir = config.ArchiveDir
+		if len(archiveDir) > 0 {
+			if err := os.MkdirAll(archiveDir, 0700); err != nil {
+				panic(fmt.Sprintf("failed to create archive_dir: %s", err))
+			}
+		}
+		archiveMaxFiles = config.ArchiveMaxFiles
+		archiveMaxAgeDays = config.ArchiveMaxAgeDays
+		if len(archiveDir) > 0 && archiveMaxFiles <= 0 && archiveMaxAgeDays <= 0 {
+			log.Printf("*** Outputs will be archived without limits: %s", archiveDir)
+		}
+		tempDir = valueOrDefault(config.TempDir, os.TempDir())
+		if err := os.MkdirAll(tempDir, 0700); err != nil {
+			panic(fmt.Sprintf("failed to create temp_dir: %s", err))
+		}
+		diskCheckPath = valueOrDefault(config.DiskCheckPath, tempDir)
+		minFreeDiskMB = config.MinFreeDiskMB
+		binaryOutputFallback = valueOrDefault(config.BinaryOutputFallback, binaryFallbackDocument)
+		useReactions = config.UseReactions
+		resultWebhook = config.ResultWebhook
+		resultWebhookRetries = config.ResultWebhookRetries
+		if resultWebhookRetries < 0 {
+			resultWebhookRetries = 0
+		} else if resultWebhookRetries == 0 {
+			resultWebhookRetries = defaultResultWebhookRetries
+		}
+		resultWebhookBackoffSeconds = config.ResultWebhookBackoffSeconds
+		if resultWebhookBackoffSeconds <= 0 {
+			resultWebhookBackoffSeconds = defaultResultWebhookBackoffSeconds
+		}
+		schedules = config.Schedules
+		if err := validateSchedules(
+			schedules,
+			scripts,
+			intOrDefault(config.MinScheduleIntervalSeconds, defaultMinScheduleIntervalSeconds),
+			intOrDefault(config.MaxSchedules, defaultMaxSchedules),
+		); err != nil {
+			panic(err.Error())
+		}
+		digestTime = config.DigestTime
+		digestChatID = config.DigestChatID
+		reactionReceived = valueOrDefault(config.ReactionReceived, defaultReactionReceived)
+		reactionSucceeded = valueOrDefault(config.ReactionSucceeded, defaultReactionSucceeded)
+		reactionFailed = valueOrDefault(config.ReactionFailed, defaultReactionFailed)
 		isVerbose = config.IsVerbose
+		logFormat = valueOrDefault(config.LogFormat, logFormatText)
+		if logFormat != logFormatText && logFormat != logFormatJSON {
+			panic(fmt.Sprintf("invalid log format: %s (%s or %s)", logFormat, logFormatText, logFormatJSON))
+		}
+		setupLogFormat(logFormat)
+
+		currentConfig = config
 
 		// initialize session variables
 		sessions := make(map[string]Session)
@@ -142,8 +784,30 @@ func init() {
 				CurrentStatus: StatusWaiting,
 			}
 		}
+		for _, v := range allowedUserIDs {
+			id := numericSessionID(v)
+			sessions[id] = Session{
+				UserID:        id,
+				CurrentStatus: StatusWaiting,
+			}
+		}
+		sessionsPath = valueOrDefault(config.SessionsPath, defaultSessionsPath(configFilePath()))
+		offsetPath = valueOrDefault(config.OffsetPath, defaultOffsetPath(configFilePath()))
+		historyPath = valueOrDefault(config.HistoryPath, defaultHistoryPath(configFilePath()))
+		if err := checkStateFiles(botConfigPaths); err != nil {
+			panic(err.Error())
+		}
+		history.resize(intOrDefault(config.HistorySize, defaultHistorySize))
+		if err := history.load(); err != nil {
+			log.Printf("*** Failed to load history: %s", err)
+		}
+		if err := loadSessions(sessions); err != nil {
+			log.Printf("*** Failed to load sessions: %s", err)
+		}
 		pool = SessionPool{
-			Sessions: sessions,
+			Sessions:           sessions,
+			ChatLastExecutedAt: make(map[int64]time.Time),
+			RateLimits:         make(map[string]TokenBucket),
 		}
 
 		// channels
@@ -153,9 +817,25 @@ func init() {
 	}
 }
 
-// check if given Telegram id is available
-func isAvailableID(id string) bool {
-	for _, v := range allowedIds {
+// get current monitor interval
+func getMonitorInterval() int {
+	monitorIntervalLock.Lock()
+	defer monitorIntervalLock.Unlock()
+
+	return monitorInterval
+}
+
+// change monitor interval
+func setMonitorInterval(interval int) {
+	monitorIntervalLock.Lock()
+	defer monitorIntervalLock.Unlock()
+
+	monitorInterval = interval
+}
+
+// check if given Telegram id is an admin's
+func isAdminID(id string) bool {
+	for _, v := range adminIds {
 		if v == id {
 			return true
 		}
@@ -163,189 +843,1650 @@ func isAvailableID(id string) bool {
 	return false
 }
 
-// process incoming update from Telegram
-func processUpdate(b *bot.Bot, update bot.Update) bool {
-	// check username
-	var userID string
-	if update.Message.From.Username == nil {
-		log.Printf("*** Not allowed (no user name): %s", update.Message.From.FirstName)
-		return false
-	}
-	userID = *update.Message.From.Username
-	if !isAvailableID(userID) {
-		log.Printf("*** Id not allowed: %s", userID)
-		return false
+// send given message to admins' chats
+func notifyAdmins(b BotClient, message string) {
+	for _, chatID := range adminChatIDs {
+		if sent := b.SendMessage(chatID, message, nil); !sent.Ok {
+			log.Printf("*** Failed to notify admin (%d): %s", chatID, *sent.Description)
+		}
 	}
+}
 
-	// process result
-	result := false
+// current config as a string, with sensitive values redacted
+func sanitizedConfig() string {
+	currentConfigLock.Lock()
+	config := currentConfig
+	currentConfigLock.Unlock()
 
-	pool.Lock()
-	if session, exists := pool.Sessions[userID]; exists {
-		// text from message
-		var txt string
-		if update.Message.HasText() {
-			txt = *update.Message.Text
-		} else {
-			txt = ""
-		}
+	config.APIToken = redactedString
+	config.MonitorInterval = getMonitorInterval() // (can be changed with /interval)
 
-		var message string
-		var options = map[string]interface{}{
-			"reply_markup": bot.ReplyKeyboardMarkup{
-				Keyboard:       allKeyboards,
-				ResizeKeyboard: true,
-			},
-			//"parse_mode": bot.ParseModeMarkdown,
+	// values of environment variables can be secrets
+	config.ScriptEnv = redactedEnv(config.ScriptEnv)
+
+	// mask credentials in urls, and secrets of scripts
+	original := config.Scripts
+	config.Scripts = map[string]Script{}
+	for name, script := range original {
+		if len(script.RTSPURL) > 0 {
+			script.RTSPURL = maskURL(script.RTSPURL)
 		}
+		script.Env = redactedEnv(script.Env)
+		config.Scripts[name] = script
+	}
+	if len(config.ResultWebhook) > 0 {
+		config.ResultWebhook = maskURL(config.ResultWebhook)
+	}
+	if config.Webhook != nil {
+		webhook := *config.Webhook // (not to change the original)
+		webhook.KeyFilepath = redactedString
+		config.Webhook = &webhook
+	}
 
-		switch session.CurrentStatus {
-		case StatusWaiting:
-			switch {
-			// start
-			case strings.HasPrefix(txt, commandStart):
-				message = messageDefault
-			// execute
-			case strings.HasPrefix(txt, commandExecute):
-				message = ""
-			// show code
-			case strings.HasPrefix(txt, commandShowCode):
-				message = readCode()
-			// fallback
-			default:
-				if len(txt) > 0 {
-					message = fmt.Sprintf("%s: %s", txt, messageUnknownCommand)
-				} else {
-					message = messageUnknownCommand
-				}
-			}
+	bytes, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return fmt.Sprintf(messageErrorFormat, err)
+	}
+
+	return string(bytes)
+}
+
+// copy of given environment variables with their values redacted
+func redactedEnv(env map[string]string) map[string]string {
+	if env == nil {
+		return nil
+	}
+
+	redacted := map[string]string{}
+	for name := range env {
+		redacted[name] = redactedString
+	}
+	return redacted
+}
+
+// check if given numeric Telegram user id is available
+func isAvailableUserID(id int64) bool {
+	allowedIdsLock.Lock()
+	defer allowedIdsLock.Unlock()
+
+	for _, v := range allowedUserIDs {
+		if v == id {
+			return true
 		}
+	}
+	return false
+}
 
-		if len(message) > 0 {
-			// 'typing...'
-			b.SendChatAction(update.Message.Chat.ID, bot.ChatActionTyping)
+// check if given user is allowed, and return the id of the user's session
+//
+// when numeric user ids are configured, only they are matched
+// (usernames are optional and can be changed, so they are matched only when no numeric ids are given)
+func authorizedUserID(user *bot.User) (userID string, allowed bool) {
+	allowedIdsLock.Lock()
+	numeric := len(allowedUserIDs) > 0
+	allowedIdsLock.Unlock()
 
-			// send message
-			if sent := b.SendMessage(update.Message.Chat.ID, message, options); sent.Ok {
-				result = true
-			} else {
-				log.Printf("*** Failed to send message: %s", *sent.Description)
-			}
+	if numeric {
+		if isAvailableUserID(int64(user.ID)) {
+			return numericSessionID(int64(user.ID)), true
+		}
+
+		if user.Username != nil && isAvailableID(*user.Username) {
+			log.Printf("*** Known username with mismatched id, not allowed: %s (%d)", *user.Username, user.ID)
 		} else {
-			// push to execute request channel
-			executeChannel <- ExecuteRequest{
-				ChatID:         update.Message.Chat.ID,
-				MessageOptions: options,
-			}
+			log.Printf("*** User id not allowed: %d (%s)", user.ID, user.FirstName)
 		}
-	} else {
-		log.Printf("*** Session does not exist for id: %s", userID)
+		return "", false
 	}
-	pool.Unlock()
 
-	return result
+	if user.Username == nil {
+		log.Printf("*** Not allowed (no user name): %s", user.FirstName)
+		return "", false
+	}
+	if !isAvailableID(*user.Username) {
+		log.Printf("*** Id not allowed: %s", *user.Username)
+		return "", false
+	}
+
+	return *user.Username, true
 }
 
-// process execute request
-func processExecuteRequest(b *bot.Bot, request ExecuteRequest) bool {
-	// process result
-	result := false
+// describe given user's id, username, and whether the user is allowed
+func whoAmIMessage(user *bot.User, allowed bool) string {
+	username := "none"
+	if user.Username != nil {
+		username = *user.Username
+	}
 
-	executeLock.Lock()
-	defer executeLock.Unlock()
+	allowedText := "no"
+	if allowed {
+		allowedText = "yes"
+	}
 
-	// 'typing...'
-	b.SendChatAction(request.ChatID, bot.ChatActionTyping)
+	return fmt.Sprintf(messageWhoAmIFormat, user.ID, username, allowedText)
+}
 
-	// execute script, read its output, and send it to the client
-	if bytes, err := exec.Command(scriptPath).CombinedOutput(); err != nil {
-		message := fmt.Sprintf("Error running script: %s (%s)", err, string(bytes))
-		log.Printf("*** %s", message)
+// id of the session for given numeric user id
+func numericSessionID(id int64) string {
+	return strconv.FormatInt(id, 10)
+}
 
-		if sent := b.SendMessage(request.ChatID, message, request.MessageOptions); sent.Ok {
-			result = true
-		} else {
-			log.Printf("*** Failed to send error message: %s", *sent.Description)
+// check if given Telegram id is available
+func isAvailableID(id string) bool {
+	allowedIdsLock.Lock()
+	defer allowedIdsLock.Unlock()
+
+	for _, v := range allowedIds {
+		if v == id {
+			return true
 		}
-	} else {
-		mime := http.DetectContentType(bytes)
+	}
+	return false
+}
 
-		if strings.HasPrefix(mime, "image") { // image type
-			b.SendChatAction(request.ChatID, bot.ChatActionUploadPhoto)
+// calculate remaining cooldown from the last execution time
+func remainingCooldown(lastExecutedAt time.Time, cooldown time.Duration, now time.Time) time.Duration {
+	if lastExecutedAt.IsZero() || cooldown <= 0 {
+		return 0
+	}
 
-			if sent := b.SendPhoto(request.ChatID, bot.InputFileFromBytes(bytes), request.MessageOptions); sent.Ok {
-				result = true
-			} else {
-				message := fmt.Sprintf("Failed to send photo: %s", *sent.Description)
-				log.Printf("*** %s", message)
+	if remaining := lastExecutedAt.Add(cooldown).Sub(now); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
 
-				if sent := b.SendMessage(request.ChatID, message, request.MessageOptions); sent.Ok {
-					result = true
-				} else {
-					log.Printf("*** Failed to send error message: %s", *sent.Description)
-				}
-			}
-		} else if strings.HasPrefix(mime, "video") { // video type
-			b.SendChatAction(request.ChatID, bot.ChatActionUploadVideo)
+// round up given duration to whole seconds
+func ceilSeconds(duration time.Duration) int {
+	return int((duration + time.Second - 1) / time.Second)
+}
 
-			if sent := b.SendVideo(request.ChatID, bot.InputFileFromBytes(bytes), request.MessageOptions); sent.Ok {
-				result = true
-			} else {
-				message := fmt.Sprintf("Failed to send video: %s", *sent.Description)
-				log.Printf("*** %s", message)
+// names of configured scripts which given user can run, sorted
+func scriptNames(userID string) []string {
+	names := []string{}
+	for name := range scripts {
+		if canRunScript(userID, name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
 
-				if sent := b.SendMessage(request.ChatID, message, request.MessageOptions); sent.Ok {
-					result = true
-				} else {
-					log.Printf("*** Failed to send error message: %s", *sent.Description)
-				}
-			}
-		} else {
-			message := string(bytes)
+	return names
+}
 
-			if sent := b.SendMessage(request.ChatID, message, request.MessageOptions); sent.Ok {
-				result = true
-			} else {
-				log.Printf("*** Failed to send message: %s", *sent.Description)
-			}
-		}
+// generate a message and an inline keyboard for given page of scripts
+func scriptsKeyboard(userID string, page int) (string, bot.InlineKeyboardMarkup) {
+	names := scriptNames(userID)
+
+	numPages := (len(names) + scriptsPerPage - 1) / scriptsPerPage
+	if page >= numPages {
+		page = numPages - 1
+	}
+	if page < 0 {
+		page = 0
 	}
 
-	return result
+	keyboard := [][]bot.InlineKeyboardButton{}
+
+	// buttons for scripts
+	for i := page * scriptsPerPage; i < len(names) && i < (page+1)*scriptsPerPage; i++ {
+		data := callbackPrefixScript + names[i]
+		keyboard = append(keyboard, []bot.InlineKeyboardButton{
+			{Text: names[i], CallbackData: &data},
+		})
+	}
+
+	// buttons for navigation
+	navigation := []bot.InlineKeyboardButton{}
+	if page > 0 {
+		data := fmt.Sprintf("%s%d", callbackPrefixPage, page-1)
+		navigation = append(navigation, bot.InlineKeyboardButton{Text: buttonPrevPage, CallbackData: &data})
+	}
+	if page < numPages-1 {
+		data := fmt.Sprintf("%s%d", callbackPrefixPage, page+1)
+		navigation = append(navigation, bot.InlineKeyboardButton{Text: buttonNextPage, CallbackData: &data})
+	}
+	if len(navigation) > 0 {
+		keyboard = append(keyboard, navigation)
+	}
+
+	return fmt.Sprintf(messageChooseScript, page+1, numPages), bot.InlineKeyboardMarkup{
+		InlineKeyboard: keyboard,
+	}
 }
 
-func main() {
-	client := bot.NewClient(apiToken)
-	client.Verbose = isVerbose
+// default options for messages
+func defaultMessageOptions() map[string]interface{} {
+	return messageOptionsWithKeyboard(allKeyboards)
+}
 
-	// get info about this bot
-	if me := client.GetMe(); me.Ok {
-		log.Printf("Launching bot: @%s (%s)", *me.Result.Username, me.Result.FirstName)
+// options for messages with given reply keyboard
+//
+// (reply keyboard is removed when only inline keyboards are used)
+func messageOptionsWithKeyboard(keyboard [][]bot.KeyboardButton) map[string]interface{} {
+	if inlineKeyboardOnly {
+		return map[string]interface{}{
+			"reply_markup": bot.ReplyKeyboardRemove{
+				RemoveKeyboard: true,
+			},
+		}
+	}
 
-		// delete webhook (getting updates will not work when wehbook is set up)
-		if unhooked := client.DeleteWebhook(); unhooked.Ok {
-			// monitor execution request channel
-			go func() {
-				for {
-					select {
-					case request := <-executeChannel:
-						processExecuteRequest(client, request) // request execution of the script
-					}
-				}
-			}()
+	return map[string]interface{}{
+		"reply_markup": bot.ReplyKeyboardMarkup{
+			Keyboard:       keyboard,
+			ResizeKeyboard: true,
+		},
+	}
+}
 
-			// wait for new updates
-			client.StartMonitoringUpdates(0, monitorInterval, func(b *bot.Bot, update bot.Update, err error) {
-				if err == nil {
-					if update.Message != nil {
-						processUpdate(b, update)
-					}
-				} else {
-					log.Printf("*** Error while receiving update (%s)", err.Error())
-				}
-			})
-		} else {
-			panic("Failed to delete webhook")
+// options for messages to the user of given session, with the keyboard of the user's role and formatting
+func sessionMessageOptions(session Session) map[string]interface{} {
+	options := messageOptionsWithKeyboard(keyboardOf(session.UserID))
+	if len(session.ParseMode) > 0 {
+		options["parse_mode"] = session.ParseMode
+	}
+	return options
+}
+
+// check cooldown and pending count of given user's session, and reserve an execution
+//
+// returns a message for the user when the execution is not allowed
+//
+// (should be called while holding pool's lock)
+func reserveExecution(userID string, chatID int64, session Session) string {
+	now := time.Now()
+
+	if isInMaintenance() {
+		return messageMaintenance
+	}
+	if maxPendingPerUser > 0 && session.PendingCount >= maxPendingPerUser {
+		return messageAlreadyPending
+	}
+	if !adminsExemptFromCooldown || !isAdminID(userID) {
+		if remaining := remainingCooldown(session.LastExecutedAt, time.Duration(cooldownSeconds)*time.Second, now); remaining > 0 {
+			return fmt.Sprintf(messageCooldownFormat, ceilSeconds(remaining))
+		}
+		if remaining := remainingCooldown(pool.ChatLastExecutedAt[chatID], time.Duration(chatCooldownSeconds)*time.Second, now); remaining > 0 {
+			return fmt.Sprintf(messageChatCooldownFormat, ceilSeconds(remaining))
+		}
+		if maxPerMinute > 0 {
+			bucket, wait := pool.RateLimits[userID].take(maxPerMinute, now)
+			pool.RateLimits[userID] = bucket
+			if wait > 0 {
+				return fmt.Sprintf(messageRateLimitedFormat, ceilSeconds(wait))
+			}
+		}
+	}
+
+	session.LastExecutedAt = now
+	session.PendingCount++
+	pool.Sessions[userID] = session
+	pool.ChatLastExecutedAt[chatID] = now
+
+	return ""
+}
+
+// ask for a reason before executing given request
+//
+// (should be called while holding pool's lock)
+func awaitReason(userID string, session Session, request ExecuteRequest) string {
+	session.CurrentStatus = StatusAwaitingReason
+	session.PendingRequest = &request
+	session.ReasonRequestedAt = time.Now()
+	pool.Sessions[userID] = session
+
+	return fmt.Sprintf(messageAskReason, request.ScriptName, reasonTimeoutSeconds)
+}
+
+// clear the request waiting for a reason, and return to the waiting status
+//
+// (should be called while holding pool's lock)
+func clearPendingRequest(userID string, session Session) Session {
+	session.CurrentStatus = StatusWaiting
+	session.PendingRequest = nil
+	session.ReasonRequestedAt = time.Time{}
+	pool.Sessions[userID] = session
+
+	return session
+}
+
+// stop configuring parameters of a script, and return to the waiting status
+//
+// (should be called while holding pool's lock)
+func clearConfiguring(userID string, session Session) Session {
+	session.CurrentStatus = StatusWaiting
+	session.ConfiguringScript = ""
+	session.ConfiguringValues = nil
+	pool.Sessions[userID] = session
+
+	return session
+}
+
+// process incoming update from Telegram
+func processUpdate(b BotClient, update bot.Update) bool {
+	// ignore messages in group chats which are not for this bot
+	if !isAddressedToBot(update.Message) {
+		return false
+	}
+
+	// ignore messages sent on behalf of chats, which cannot be authorized
+	if !isSentByUser(update.Message) {
+		log.Printf("*** Ignoring message without a user sender in chat: %d", update.Message.Chat.ID)
+		return false
+	}
+
+	// check user
+	userID, allowed := authorizedUserID(update.Message.From)
+	if !allowed {
+		// let users who are not allowed know their ids (without letting them execute anything)
+		if txt, exists := messageText(update.Message); exists {
+			if command, _ := parseCommand(txt); command == commandWhoAmI {
+				if isVerbose {
+					log.Printf("Probed with %s by user: %d", commandWhoAmI, update.Message.From.ID)
+				}
+
+				sent := b.SendMessage(update.Message.Chat.ID, whoAmIMessage(update.Message.From, false), map[string]interface{}{})
+				if !sent.Ok {
+					log.Printf("*** Failed to send message: %s", *sent.Description)
+				}
+				return sent.Ok
+			}
+		}
+
+		alertUnauthorized(b, update.Message)
+
+		return false
+	}
+
+	// process result
+	result := false
+
+	// reply, request to be pushed, and function to be run after releasing the pool lock
+	//
+	// (not sent while holding the lock, as retried sends can take long and block other users)
+	var reply string
+	var options map[string]interface{}
+	var request *ExecuteRequest
+	var deferred func() bool
+
+	pool.Lock()
+	if session, exists := pool.Sessions[userID]; exists {
+		// text from message (or caption of a photo)
+		txt, _ := messageText(update.Message)
+		txt = stripMention(txt)
+
+		command, args := parseCommand(txt)
+
+		// photos are executed with the script as its input
+		var inputFileID string
+		if update.Message.HasPhoto() {
+			if !strings.HasPrefix(txt, "/") {
+				command, args = commandExecute, txt // eg. "edges" => "/execute edges"
+			}
+			if command == commandExecute {
+				inputFileID = update.Message.LargestPhoto().FileID
+			}
+		}
+		if strings.HasPrefix(command, "/") {
+			logEvent("Command received", map[string]interface{}{
+				"user_id": userID,
+				"command": command,
+			})
+		}
+		c := &CommandContext{
+			Bot:     b,
+			Message: update.Message,
+			UserID:  userID,
+			Args:    args,
+			Session: session,
+			Options: sessionMessageOptions(session),
+
+			InputFileID: inputFileID,
+		}
+
+		// expire the prompt for a reason
+		if session.CurrentStatus == StatusAwaitingReason && time.Since(session.ReasonRequestedAt) > time.Duration(reasonTimeoutSeconds)*time.Second {
+			log.Printf("Prompt for a reason expired for id: %s", userID)
+
+			session = clearPendingRequest(userID, session)
+			c.Session = session
+		}
+
+		switch session.CurrentStatus {
+		case StatusAwaitingReason:
+			if command == commandCancel {
+				clearPendingRequest(userID, session)
+
+				c.Reply = messageCancelled
+			} else if reason := strings.TrimSpace(txt); len(reason) <= 0 {
+				c.Reply = fmt.Sprintf(messageAskReason, session.PendingRequest.ScriptName, reasonTimeoutSeconds)
+			} else {
+				pending := *session.PendingRequest
+				session = clearPendingRequest(userID, session)
+
+				if c.Reply = reserveExecution(userID, update.Message.Chat.ID, session); len(c.Reply) <= 0 {
+					pending.Reason = reason
+					c.Request = &pending
+
+					audit(AuditEntry{
+						Time:   time.Now(),
+						UserID: userID,
+						Script: pending.ScriptName,
+						Args:   pending.Args,
+						Reason: reason,
+					})
+				}
+			}
+		case StatusCollectingParameters:
+			if command == commandCancel {
+				handleCancel(c)
+			} else {
+				handleParameterValue(c, strings.TrimSpace(txt))
+			}
+		case StatusAwaitingScriptChoice, StatusAwaitingArgs:
+			if command == commandCancel {
+				handleCancel(c)
+			} else if handler, exists := commandHandlers[command]; exists {
+				// other commands end the flow
+				c.Session = clearConfiguring(userID, session)
+				handler(c)
+			} else if session.CurrentStatus == StatusAwaitingScriptChoice {
+				handleScriptChoice(c, strings.TrimSpace(txt))
+			} else {
+				handleArgsValue(c, strings.TrimSpace(txt))
+			}
+		case StatusWaiting:
+			if handler, exists := commandHandlers[command]; exists {
+				handler(c)
+			} else {
+				// fallback
+				if len(txt) > 0 {
+					c.Reply = fmt.Sprintf("%s: %s", txt, messageUnknownCommand)
+				} else {
+					c.Reply = messageUnknownCommand
+				}
+				if suggestion := closestCommand(valueOrDefault(command, firstWord(txt))); len(suggestion) > 0 {
+					c.Reply = fmt.Sprintf("%s "+messageDidYouMeanFormat, c.Reply, suggestion)
+				}
+			}
+		}
+
+		reply, options = c.Reply, c.Options
+		request = c.Request
+		deferred = c.Deferred
+	} else {
+		log.Printf("*** Session does not exist for id: %s", userID)
+	}
+	saveSessions()
+	pool.Unlock()
+
+	if len(reply) > 0 {
+		// 'typing...'
+		b.SendChatAction(update.Message.Chat.ID, bot.ChatActionTyping)
+
+		// send message
+		if sent := b.SendMessage(update.Message.Chat.ID, reply, options); sent.Ok {
+			result = true
+		} else {
+			log.Printf("*** Failed to send message: %s", *sent.Description)
+		}
+	}
+
+	if deferred != nil {
+		result = deferred()
+	}
+
+	if request != nil {
+		// acknowledge receipt with a reaction
+		request.Reacted = useReactions && setMessageReaction(b, request.ChatID, request.MessageID, reactionReceived)
+
+		// push to execute request channel, and tell the position in the queue
+		var message string
+		if position, queued := enqueueRequest(*request); queued {
+			message = fmt.Sprintf(messageQueuePositionFormat, position)
+		} else {
+			finishPendingRequest(userID)
+
+			if request.Reacted {
+				setMessageReaction(b, request.ChatID, request.MessageID, reactionFailed)
+			}
+			message = queueRejectedMessage()
+		}
+
+		if sent := b.SendMessage(request.ChatID, message, request.MessageOptions); sent.Ok {
+			result = true
+		} else {
+			log.Printf("*** Failed to send message: %s", *sent.Description)
+		}
+	}
+
+	return result
+}
+
+// process incoming callback query from Telegram
+func processCallbackQuery(b BotClient, update bot.Update) bool {
+	query := update.CallbackQuery
+
+	// check user
+	userID, allowed := authorizedUserID(&query.From)
+	if !allowed {
+		return false
+	}
+	if query.Message == nil || query.Data == nil {
+		log.Printf("*** Callback query without message or data from id: %s", userID)
+		return false
+	}
+
+	// process result
+	result := false
+
+	data := *query.Data
+	var answer string
+	var request *ExecuteRequest
+
+	// message and keyboard for updating the query's message
+	var message string
+	var keyboard bot.InlineKeyboardMarkup
+
+	switch {
+	// navigate pages of scripts
+	case strings.HasPrefix(data, callbackPrefixPage):
+		page, _ := strconv.Atoi(strings.TrimPrefix(data, callbackPrefixPage))
+		message, keyboard = scriptsKeyboard(userID, page)
+	// execute chosen script, or start configuring its parameters
+	case strings.HasPrefix(data, callbackPrefixScript):
+		name := strings.TrimPrefix(data, callbackPrefixScript)
+
+		if !canRunScript(userID, name) {
+			log.Printf("*** Script not permitted for %s: %s", userID, name)
+
+			answer = messageScriptNotPermitted
+		} else if script, exists := scripts[name]; exists {
+			pool.Lock()
+			if session, exists := pool.Sessions[userID]; exists {
+				// (chosen with the keyboard instead of a message)
+				if session.CurrentStatus == StatusAwaitingScriptChoice {
+					session.CurrentStatus = StatusWaiting
+					pool.Sessions[userID] = session
+				}
+
+				if len(script.Parameters) > 0 {
+					session.ConfiguringScript = name
+					session.ConfiguringValues = nil
+					pool.Sessions[userID] = session
+
+					message, keyboard = parameterKeyboard(name, script, 0)
+				} else {
+					execute := ExecuteRequest{
+						UserID:         userID,
+						ChatID:         query.Message.Chat.ID,
+						MessageID:      query.Message.MessageID,
+						ScriptName:     name,
+						ScriptPath:     script.Path,
+						MessageOptions: sessionMessageOptions(session),
+					}
+
+					session.LastScript, session.LastArgs = name, execute.Args
+
+					if script.RequiresConfirmation {
+						pool.Sessions[userID] = session
+
+						message, keyboard = awaitConfirmation(execute)
+					} else if script.RequireReason {
+						message, keyboard = awaitReason(userID, session, execute), bot.InlineKeyboardMarkup{InlineKeyboard: [][]bot.InlineKeyboardButton{}}
+					} else if answer = reserveExecution(userID, query.Message.Chat.ID, session); len(answer) <= 0 {
+						answer = fmt.Sprintf(messageExecuting, name)
+
+						request = &execute
+					}
+				}
+			} else {
+				log.Printf("*** Session does not exist for id: %s", userID)
+			}
+			saveSessions()
+			pool.Unlock()
+		} else {
+			answer = messageNoSuchScript
+		}
+	// choose the value of a parameter
+	case strings.HasPrefix(data, callbackPrefixValue):
+		value := strings.TrimPrefix(data, callbackPrefixValue)
+
+		pool.Lock()
+		if session, exists := pool.Sessions[userID]; exists {
+			if script, exists := scripts[session.ConfiguringScript]; exists && len(session.ConfiguringValues) < len(script.Parameters) {
+				name := session.ConfiguringScript
+				index := len(session.ConfiguringValues)
+
+				if !script.Parameters[index].allows(value) {
+					log.Printf("*** Value not allowed for parameter '%s' of script '%s': %s", script.Parameters[index].Name, name, value)
+
+					answer = messageInvalidValue
+				} else if index+1 < len(script.Parameters) {
+					session.ConfiguringValues = append(session.ConfiguringValues, value)
+					pool.Sessions[userID] = session
+
+					message, keyboard = parameterKeyboard(name, script, index+1)
+				} else {
+					values := append(session.ConfiguringValues, value)
+					session = clearConfiguring(userID, session)
+
+					execute := ExecuteRequest{
+						UserID:         userID,
+						ChatID:         query.Message.Chat.ID,
+						MessageID:      query.Message.MessageID,
+						ScriptName:     name,
+						ScriptPath:     script.Path,
+						Args:           parameterArgs(script, values),
+						MessageOptions: sessionMessageOptions(session),
+					}
+
+					session.LastScript, session.LastArgs = name, execute.Args
+
+					if script.RequiresConfirmation {
+						pool.Sessions[userID] = session
+
+						message, keyboard = awaitConfirmation(execute)
+					} else if script.RequireReason {
+						message, keyboard = awaitReason(userID, session, execute), bot.InlineKeyboardMarkup{InlineKeyboard: [][]bot.InlineKeyboardButton{}}
+					} else if answer = reserveExecution(userID, query.Message.Chat.ID, session); len(answer) <= 0 {
+						answer = fmt.Sprintf(messageExecuting, name)
+
+						request = &execute
+					}
+				}
+			} else {
+				answer = messageNotConfiguring
+			}
+		} else {
+			log.Printf("*** Session does not exist for id: %s", userID)
+		}
+		saveSessions()
+		pool.Unlock()
+	// execute the confirmed request
+	case strings.HasPrefix(data, callbackPrefixConfirm):
+		execute, rejected := confirmations.take(strings.TrimPrefix(data, callbackPrefixConfirm), userID, time.Now())
+		if len(rejected) > 0 {
+			answer = rejected
+			if rejected == messageConfirmationExpired {
+				message, keyboard = rejected, bot.InlineKeyboardMarkup{InlineKeyboard: [][]bot.InlineKeyboardButton{}}
+			}
+			break
+		}
+
+		pool.Lock()
+		if session, exists := pool.Sessions[userID]; exists {
+			if scripts[execute.ScriptName].RequireReason {
+				message, keyboard = awaitReason(userID, session, execute), bot.InlineKeyboardMarkup{InlineKeyboard: [][]bot.InlineKeyboardButton{}}
+			} else if answer = reserveExecution(userID, query.Message.Chat.ID, session); len(answer) <= 0 {
+				answer = fmt.Sprintf(messageExecuting, execute.ScriptName)
+				message, keyboard = answer, bot.InlineKeyboardMarkup{InlineKeyboard: [][]bot.InlineKeyboardButton{}}
+
+				request = &execute
+			}
+		} else {
+			log.Printf("*** Session does not exist for id: %s", userID)
+		}
+		saveSessions()
+		pool.Unlock()
+	// discard the request
+	case strings.HasPrefix(data, callbackPrefixDeny):
+		if _, rejected := confirmations.take(strings.TrimPrefix(data, callbackPrefixDeny), userID, time.Now()); len(rejected) > 0 && rejected != messageConfirmationExpired {
+			answer = rejected
+		} else {
+			message, keyboard = messageCancelled, bot.InlineKeyboardMarkup{InlineKeyboard: [][]bot.InlineKeyboardButton{}}
+		}
+	}
+
+	// update the message with a new keyboard
+	if len(message) > 0 {
+		if edited := b.EditMessageText(message, map[string]interface{}{
+			"chat_id":      query.Message.Chat.ID,
+			"message_id":   query.Message.MessageID,
+			"reply_markup": keyboard,
+		}); edited.Ok {
+			result = true
+		} else {
+			log.Printf("*** Failed to edit message: %s", *edited.Description)
+		}
+	}
+
+	if request != nil {
+		// push to execute request channel, and tell the position in the queue
+		if position, queued := enqueueRequest(*request); queued {
+			answer = appendLine(answer, fmt.Sprintf(messageQueuePositionFormat, position))
+
+			result = true
+		} else {
+			finishPendingRequest(userID)
+
+			answer = queueRejectedMessage()
+		}
+	}
+
+	// answer callback query (dismisses the spinner)
+	options := map[string]interface{}{}
+	if len(answer) > 0 {
+		options["text"] = answer
+	}
+	if answered := b.AnswerCallbackQuery(query.ID, options); !answered.Ok {
+		log.Printf("*** Failed to answer callback query: %s", *answered.Description)
+	}
+
+	return result
+}
+
+// push given request to the execute request channel without blocking
+//
+// returns the position of the request in the queue, or false when the queue is full
+func enqueueRequest(request ExecuteRequest) (position int, queued bool) {
+	if isShuttingDown() || isInMaintenance() {
+		return 0, false
+	}
+
+	request.RequestedAt = time.Now()
+	request.Device = scripts[request.ScriptName].Device
+	request.Teardown = scripts[request.ScriptName].TeardownCommand
+	if len(request.Pipeline) > 0 {
+		request.Device = scripts[request.Pipeline[0]].Device
+		request.Teardown = scripts[request.Pipeline[0]].TeardownCommand
+	}
+
+	// (increased before pushing, so that it does not go below zero when the request is taken right away)
+	position = int(atomic.AddInt32(&queueLength, 1))
+
+	select {
+	case executeChannel <- request:
+		return position, true
+	default:
+		atomic.AddInt32(&queueLength, -1)
+
+		return 0, false
+	}
+}
+
+// decrease the number of pending requests of given user
+func finishPendingRequest(userID string) {
+	pool.Lock()
+	defer pool.Unlock()
+
+	if session, exists := pool.Sessions[userID]; exists && session.PendingCount > 0 {
+		session.PendingCount--
+		pool.Sessions[userID] = session
+	}
+}
+
+// check if given queued request was cancelled by its user
+func isCancelledRequest(request ExecuteRequest) bool {
+	pool.Lock()
+	defer pool.Unlock()
+
+	session, exists := pool.Sessions[request.UserID]
+	return exists && !session.CancelledBefore.IsZero() && !request.RequestedAt.After(session.CancelledBefore)
+}
+
+// run teardown command (if any) after an execution
+//
+// (should be called while holding the device's lock, so it is killed after `teardown_timeout_seconds`)
+func runTeardown(command string) {
+	if len(command) <= 0 {
+		command = teardownCommand
+	}
+	args := strings.Fields(command)
+	if len(args) <= 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(teardownTimeoutSeconds)*time.Second)
+	defer cancel()
+
+	bytes, err := exec.CommandContext(ctx, args[0], args[1:]...).CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		logEvent("*** Teardown command timed out", map[string]interface{}{"command": command, "timeout_seconds": teardownTimeoutSeconds})
+	} else if err != nil {
+		logEvent("*** Teardown command failed", map[string]interface{}{"command": command, "error": err.Error(), "output": string(bytes)})
+	} else if isVerbose {
+		logEvent("Teardown command finished", map[string]interface{}{"command": command, "output": string(bytes)})
+	}
+}
+
+// run camera reset command and report its result
+func processCameraReset(b BotClient, chatID interface{}, options map[string]interface{}) bool {
+	// process result
+	result := false
+
+	// wait for the in-flight execution on the default camera (if any)
+	lock := deviceLock(defaultDevice)
+	lock.Lock()
+	defer lock.Unlock()
+
+	// 'typing...'
+	b.SendChatAction(chatID, bot.ChatActionTyping)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cameraResetTimeoutSeconds)*time.Second)
+	defer cancel()
+
+	args := strings.Fields(cameraResetCommand)
+	bytes, err := exec.CommandContext(ctx, args[0], args[1:]...).CombinedOutput()
+
+	var message string
+	if ctx.Err() == context.DeadlineExceeded {
+		message = fmt.Sprintf("Camera reset timed out after %d seconds.", cameraResetTimeoutSeconds)
+	} else if err != nil {
+		message = fmt.Sprintf("Camera reset failed: %s (%s)", err, string(bytes))
+	} else {
+		message = fmt.Sprintf("Camera reset finished: %s", string(bytes))
+	}
+	log.Printf("%s", message)
+
+	if sent := b.SendMessage(chatID, message, options); sent.Ok {
+		result = true
+	} else {
+		log.Printf("*** Failed to send message: %s", *sent.Description)
+	}
+
+	return result
+}
+
+// format given number of bytes in MB
+func formatMB(bytes uint64) string {
+	return fmt.Sprintf("%.1f MB", float64(bytes)/1024/1024)
+}
+
+// check if there is enough free disk space for capturing
+//
+// returns a message for the user when there is not enough space
+func checkDiskSpace() string {
+	if minFreeDiskMB <= 0 {
+		return ""
+	}
+
+	free, err := freeDiskSpace(diskCheckPath)
+	if err != nil {
+		log.Printf("*** Failed to check disk space: %s", err)
+		return ""
+	}
+	if free < uint64(minFreeDiskMB)*1024*1024 {
+		return fmt.Sprintf(messageNotEnoughDiskSpace, formatMB(free), diskCheckPath, minFreeDiskMB)
+	}
+
+	return ""
+}
+
+// select an error message for given user with the configured verbosity
+//
+// (admins always get the full message)
+func errorMessageForUser(userID, full, summary string) string {
+	if isAdminID(userID) {
+		return full
+	}
+
+	switch errorVerbosity {
+	case errorVerbositySummary:
+		return summary
+	case errorVerbosityGeneric:
+		return messageGenericError
+	default:
+		return full
+	}
+}
+
+// send the details of a failed execution to the error chat
+func reportError(b BotClient, request ExecuteRequest, err error, stderr []byte) {
+	message := fmt.Sprintf(messageErrorReportFormat,
+		valueOrDefault(request.ScriptName, request.ScriptPath),
+		strings.Join(request.Args, " "),
+		request.UserID,
+		exitCodeOf(err),
+		err,
+		strings.TrimSpace(string(stderr)),
+	)
+	message = splitMessage(message, messageChunkBytes)[0] // (stderr can be too long)
+
+	if sent := b.SendMessage(errorChatID, message, nil); !sent.Ok {
+		logEvent("*** Failed to send error report", requestFields(request, map[string]interface{}{"error": *sent.Description}))
+	}
+}
+
+// format duration of an execution
+func formatDuration(duration time.Duration) string {
+	return fmt.Sprintf("took %.1fs", duration.Seconds())
+}
+
+// append given line to the message (if the line is not empty)
+func appendLine(message, line string) string {
+	if len(line) <= 0 {
+		return message
+	}
+	if len(message) <= 0 {
+		return line
+	}
+	return message + "\n\n" + line
+}
+
+// split given text into chunks of at most max bytes, without breaking utf-8 characters
+//
+// (splits at the last newline in a chunk, if any)
+func splitMessage(s string, max int) []string {
+	chunks := []string{}
+	if max <= 0 {
+		return chunks
+	}
+
+	for len(s) > max {
+		// back off to the start of a rune
+		end := max
+		for end > 0 && !utf8.RuneStart(s[end]) {
+			end--
+		}
+		if end <= 0 { // max is smaller than a rune
+			_, size := utf8.DecodeRuneInString(s)
+			end = size
+		}
+
+		if i := strings.LastIndex(s[:end], "\n"); i > 0 {
+			end = i + 1
+		}
+
+		chunks = append(chunks, s[:end])
+		s = s[end:]
+	}
+	if len(s) > 0 {
+		chunks = append(chunks, s)
+	}
+
+	return chunks
+}
+
+// copy message options with given caption (if it is not empty)
+func optionsWithCaption(options map[string]interface{}, caption string) map[string]interface{} {
+	if len(caption) <= 0 {
+		return options
+	}
+
+	copied := copyOptions(options)
+	copied["caption"] = caption
+
+	return copied
+}
+
+// copy of given message options
+func copyOptions(options map[string]interface{}) map[string]interface{} {
+	copied := map[string]interface{}{}
+	for k, v := range options {
+		copied[k] = v
+	}
+	return copied
+}
+
+// summarize binary output for sending as a text message
+func summarizeBinary(bytes []byte, fallback string) string {
+	head := bytes
+	if len(head) > binarySummaryNumBytes {
+		head = head[:binarySummaryNumBytes]
+	}
+
+	switch fallback {
+	case binaryFallbackHex:
+		return fmt.Sprintf("Binary output (%d bytes):\n%s", len(bytes), hex.Dump(head))
+	case binaryFallbackBase64:
+		return fmt.Sprintf("Binary output (%d bytes):\n%s", len(bytes), base64.StdEncoding.EncodeToString(head))
+	default:
+		return fmt.Sprintf("Script returned unrecognizable binary output (%d bytes, %s).", len(bytes), detectContentType(bytes))
+	}
+}
+
+// run a script with given arguments and resource limits in given directory, and return its stdout and stderr
+//
+// (stderr is kept separately, so that warnings of scripts do not corrupt their outputs)
+func runScript(path string, args []string, stdin []byte, env []string, dir string, timeout time.Duration, memoryLimitMB, cpuLimitSeconds int, execution *RunningExecution) (stdout, stderr []byte, err error) {
+	ctx, kill := context.WithCancel(context.Background())
+	defer kill()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	// kill the script when its output grows too large
+	output, errOutput := newLimitedBuffer(maxOutputBytes, kill), newLimitedBuffer(maxOutputBytes, kill)
+
+	// (relative paths would be resolved from the working directory, while bare names are looked up in PATH)
+	if strings.ContainsRune(path, filepath.Separator) {
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+	}
+
+	// (resource limits are applied before the script starts)
+	command, commandArgs, err := withResourceLimits(path, args, memoryLimitMB, cpuLimitSeconds)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to apply resource limits: %s", err)
+	}
+
+	cmd := exec.CommandContext(ctx, command, commandArgs...)
+	cmd.Dir = dir
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	setProcessGroup(cmd)
+	cmd.WaitDelay = scriptWaitDelaySeconds * time.Second // (children could keep the outputs open after the script is killed)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	cmd.Stdout = output
+	cmd.Stderr = errOutput
+
+	// pick frames (of streaming scripts) or progress lines out of the output
+	var frames *FrameWriter
+	var progress *ProgressWriter
+	if execution != nil && execution.OnFrame != nil {
+		frames = newFrameWriter(output, maxOutputBytes, execution.OnFrame, kill)
+		cmd.Stdout = frames
+	} else if execution != nil && execution.OnProgress != nil {
+		progress = newProgressWriter(output, execution.OnProgress)
+		cmd.Stdout = progress
+	}
+
+	if err := cmd.Start(); err != nil {
+		return output.Bytes(), errOutput.Bytes(), err
+	}
+	execution.started(cmd)
+
+	err = cmd.Wait()
+	if frames != nil {
+		frames.flush()
+	}
+	if progress != nil {
+		progress.flush()
+	}
+	if output.Exceeded() || errOutput.Exceeded() || (frames != nil && frames.Exceeded()) {
+		err = errOutputTooLarge
+	} else if err != nil && ctx.Err() == context.DeadlineExceeded {
+		err = errScriptTimedOut
+	} else if err != nil && (memoryLimitMB > 0 || cpuLimitSeconds > 0) && exceededResourceLimits(cmd.ProcessState) {
+		err = fmt.Errorf("killed for exceeding resource limits (%s)", err)
+	}
+
+	return output.Bytes(), errOutput.Bytes(), err
+}
+
+// send given chat action repeatedly for given duration, or until stopped
+func keepChatAction(b BotClient, chatID interface{}, action bot.ChatAction, duration time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(chatActionIntervalSeconds * time.Second)
+	defer ticker.Stop()
+
+	timeout := time.After(duration)
+	for {
+		b.SendChatAction(chatID, action)
+
+		select {
+		case <-ticker.C:
+		case <-timeout:
+			return
+		case <-stop:
+			return
+		}
+	}
+}
+
+// process execute request
+func processExecuteRequest(b BotClient, request ExecuteRequest) bool {
+	// process result
+	result := false
+
+	if !request.Immediate {
+		atomic.AddInt32(&queueLength, -1)
+	}
+
+	defer finishPendingRequest(request.UserID)
+
+	if !acquireExecution() {
+		notifyShutdown(b, request)
+		return result
+	}
+	defer releaseExecution()
+
+	if isCancelledRequest(request) {
+		logEvent("Skipping cancelled request", requestFields(request, nil))
+		return result
+	}
+
+	// serve the same request from the cache, without waiting for the device
+	if sendCachedResult(b, request) {
+		if request.Reacted {
+			setMessageReaction(b, request.ChatID, request.MessageID, reactionSucceeded)
+		}
+		return true
+	}
+
+	// wait for the device to be available (or fail immediately when it is busy)
+	lock := deviceLock(request.Device)
+	if request.Immediate {
+		if !lock.TryLock() {
+			if sent := b.SendMessage(request.ChatID, messageDeviceBusy, request.MessageOptions); sent.Ok {
+				result = true
+			} else {
+				logEvent("*** Failed to send message", requestFields(request, map[string]interface{}{"error": *sent.Description}))
+			}
+			return result
+		}
+	} else {
+		lock.Lock()
+	}
+	defer lock.Unlock()
+
+	// (could be cancelled while waiting for the device)
+	if isCancelledRequest(request) {
+		logEvent("Skipping cancelled request", requestFields(request, nil))
+		return result
+	}
+
+	// let the camera reinitialize after its last use
+	if cooldown := deviceCooldown(request.Device); cooldown > 0 {
+		if sent := b.SendMessage(request.ChatID, fmt.Sprintf(messageWarmingUpFormat, ceilSeconds(cooldown)), request.MessageOptions); !sent.Ok {
+			logEvent("*** Failed to send message", requestFields(request, map[string]interface{}{"error": *sent.Description}))
+		}
+		time.Sleep(cooldown)
+	}
+	markDeviceInUse(request.Device, request)
+	defer markDeviceReleased(request.Device)
+
+	// reset things before releasing the lock
+	defer runTeardown(request.Teardown)
+
+	// send results of scheduled executions silently
+	//
+	// (options are copied, as the caller's map can be read while sending other messages)
+	request.MessageOptions = copyOptions(request.MessageOptions)
+	if isBackgroundRequest(request) && (disableNotification || scripts[request.ScriptName].DisableNotification) {
+		request.MessageOptions["disable_notification"] = true
+	}
+
+	// whether the script's output was delivered successfully
+	succeeded := false
+
+	// delivered output, its mime type, and caption
+	var output []byte
+	var outputMime, outputCaption string
+
+	// how long the script ran
+	var duration time.Duration
+
+	defer func() {
+		// image to be included in the digest
+		var image []byte
+		if succeeded && isPhoto(outputMime) {
+			image = output
+		}
+		stats.record(succeeded, image)
+		lastRun.record(request, succeeded)
+		metrics.record(valueOrDefault(request.ScriptName, request.ScriptPath), succeeded, duration)
+
+		outcome := "succeeded"
+		if !succeeded {
+			outcome = "failed"
+		}
+		logEvent("Execution finished", map[string]interface{}{
+			"user_id":     request.UserID,
+			"script":      valueOrDefault(request.ScriptName, request.ScriptPath),
+			"duration_ms": duration.Milliseconds(),
+			"result":      outcome,
+		})
+
+		if succeeded {
+			forwardResult(b, request, outputMime, output)
+			archiveOutput(request, outputMime, output)
+
+			if len(output) > 0 {
+				lastOutputs.store(request.UserID, output, outputMime, outputCaption)
+			}
+			cacheResult(request, output, outputMime, outputCaption)
+		}
+	}()
+
+	if request.Reacted {
+		// mark the result on the triggering message
+		defer func() {
+			if succeeded {
+				setMessageReaction(b, request.ChatID, request.MessageID, reactionSucceeded)
+			} else {
+				setMessageReaction(b, request.ChatID, request.MessageID, reactionFailed)
+			}
+		}()
+	} else if request.ClipSeconds <= 0 {
+		// 'typing...'
+		b.SendChatAction(request.ChatID, bot.ChatActionTyping)
+	}
+
+	if request.ClipSeconds > 0 {
+		// 'recording video...' while recording a clip
+		stop := make(chan struct{})
+		defer close(stop)
+		go keepChatAction(b, request.ChatID, bot.ChatActionRecordVideo, time.Duration(request.ClipSeconds)*time.Second, stop)
+	}
+
+	// check disk space before capturing
+	if message := checkDiskSpace(); len(message) > 0 {
+		logEvent(errorLogPrefix+message, requestFields(request, nil))
+
+		if sent := b.SendMessage(request.ChatID, message, request.MessageOptions); sent.Ok {
+			result = true
+		} else {
+			logEvent("*** Failed to send error message", requestFields(request, map[string]interface{}{"error": *sent.Description}))
+		}
+
+		return result
+	}
+
+	// download the uploaded photo, and pass it to the script
+	args := request.Args
+	if len(request.InputFileID) > 0 {
+		path, err := downloadInputPhoto(b, request.InputFileID)
+		if err != nil {
+			logEvent("*** Failed to download input photo", requestFields(request, map[string]interface{}{"error": err.Error()}))
+
+			if sent := b.SendMessage(request.ChatID, messageInputPhotoFailed, request.MessageOptions); sent.Ok {
+				result = true
+			} else {
+				logEvent("*** Failed to send error message", requestFields(request, map[string]interface{}{"error": *sent.Description}))
+			}
+
+			return result
+		}
+		defer os.Remove(path)
+
+		args = append(append([]string{}, args...), path)
+	}
+
+	// execute script, read its output, and send it to the client
+	script := scripts[request.ScriptName]
+	timeout := script.timeout()
+	if request.TimeoutSeconds > 0 {
+		timeout = time.Duration(request.TimeoutSeconds) * time.Second
+	}
+	if timeout > 0 && request.ClipSeconds > 0 {
+		timeout += time.Duration(request.ClipSeconds) * time.Second // give time for recording the clip
+	}
+	removeOutputFile(script.OutputFile) // (not to send a stale one)
+	execution := startExecution(request.UserID)
+	progress := startProgressReporter(b, request.ChatID, map[string]interface{}{"disable_notification": true})
+	if script.Streaming {
+		execution.OnFrame = func(frame []byte) {
+			sendFrame(b, request, frame)
+		}
+	} else {
+		execution.OnProgress = progress.update
+	}
+	startedAt := time.Now()
+	var bytes, errBytes []byte
+	var err error
+	if len(request.Pipeline) > 0 {
+		bytes, errBytes, err = runPipeline(request.Pipeline, execution)
+	} else {
+		command, commandArgs := script.command(request.ScriptPath, args)
+		bytes, errBytes, err = runScript(command, commandArgs, nil, script.environment(), script.workDir(request.ScriptPath), timeout, script.MemoryLimitMB, script.CPULimitSeconds, execution)
+	}
+	endExecution(execution)
+	progress.finish()
+	if err == nil && len(errBytes) > 0 {
+		logEvent("Script printed to stderr", requestFields(request, map[string]interface{}{"stderr": strings.TrimSpace(string(errBytes))}))
+	}
+	if err == nil && len(script.OutputFile) > 0 {
+		if len(bytes) > 0 {
+			logEvent("Script printed to stdout", requestFields(request, map[string]interface{}{"stdout": strings.TrimSpace(string(bytes))}))
+		}
+		bytes, err = readOutputFile(script.OutputFile)
+	}
+	duration = time.Since(startedAt)
+	history.record(HistoryEntry{
+		Timestamp:  startedAt,
+		UserID:     request.UserID,
+		Script:     valueOrDefault(request.ScriptName, request.ScriptPath),
+		Args:       request.Args,
+		ExitCode:   exitCodeOf(err),
+		DurationMs: duration.Milliseconds(),
+	})
+
+	// show duration of the execution
+	var durationText string
+	if showDuration || script.ShowDuration {
+		durationText = formatDuration(duration)
+	}
+
+	if execution.wasCancelled() {
+		logEvent("Execution cancelled", requestFields(request, nil))
+
+		if sent := b.SendMessage(request.ChatID, messageExecutionCancelled, request.MessageOptions); sent.Ok {
+			result = true
+		} else {
+			logEvent("*** Failed to send message", requestFields(request, map[string]interface{}{"error": *sent.Description}))
+		}
+	} else if err == errScriptTimedOut {
+		message := fmt.Sprintf(messageTimedOutFormat, int(timeout.Seconds()))
+		logEvent(errorLogPrefix+message, requestFields(request, nil))
+
+		if sent := b.SendMessage(request.ChatID, message, request.MessageOptions); sent.Ok {
+			result = true
+		} else {
+			logEvent("*** Failed to send error message", requestFields(request, map[string]interface{}{"error": *sent.Description}))
+		}
+	} else if err == errOutputTooLarge {
+		message := fmt.Sprintf(messageOutputTooLargeFormat, formatMB(uint64(maxOutputBytes)))
+		logEvent(errorLogPrefix+message, requestFields(request, nil))
+
+		if sent := b.SendMessage(request.ChatID, message, request.MessageOptions); sent.Ok {
+			result = true
+		} else {
+			logEvent("*** Failed to send error message", requestFields(request, map[string]interface{}{"error": *sent.Description}))
+		}
+	} else if err != nil {
+		message := appendLine(fmt.Sprintf("Error running script: %s (%s)", err, strings.TrimSpace(string(errBytes))), durationText)
+		logEvent(errorLogPrefix+message, requestFields(request, nil))
+
+		if errorChatID != 0 {
+			// details to the error chat, and a brief message to the user
+			reportError(b, request, err, errBytes)
+
+			message = messageExecutionFailed
+		} else {
+			message = errorMessageForUser(request.UserID, message, fmt.Sprintf("Error running script: %s", err))
+		}
+
+		if sent := b.SendMessage(request.ChatID, message, request.MessageOptions); sent.Ok {
+			result = true
+		} else {
+			logEvent("*** Failed to send error message", requestFields(request, map[string]interface{}{"error": *sent.Description}))
+		}
+	} else {
+		// caption (and the way of sending) from the header lines
+		var meta []byte
+		var caption, sendAs string
+		meta, caption, sendAs, bytes = splitHeaders(bytes)
+		if len(sendAs) > 0 && !isSendAs(sendAs) {
+			logEvent("*** Unknown way of sending output", requestFields(request, map[string]interface{}{"send_as": sendAs}))
+			sendAs = ""
+		}
+		caption = appendLine(caption, renderCaption(valueOrDefault(script.CaptionTemplate, captionTemplate), meta))
+		caption = truncateCaption(appendLine(caption, durationText))
+
+		mime := detectContentType(bytes)
+		output, outputMime, outputCaption = bytes, mime, caption
+
+		if dir, isAlbum := albumDir(bytes); isAlbum && len(sendAs) <= 0 { // multiple images or videos
+			b.SendChatAction(request.ChatID, bot.ChatActionUploadPhoto)
+
+			items, err := readAlbum(dir)
+			if err == nil {
+				err = sendAlbum(b, request.ChatID, items, caption, request.MessageOptions)
+			}
+			removeAlbum(dir)
+
+			if err == nil {
+				output, outputMime = nil, items[0].Mime
+
+				result = true
+				succeeded = true
+			} else {
+				message := fmt.Sprintf("Failed to send album: %s", err)
+				logEvent(errorLogPrefix+message, requestFields(request, nil))
+
+				message = errorMessageForUser(request.UserID, message, "Failed to send album.")
+
+				if sent := b.SendMessage(request.ChatID, message, request.MessageOptions); sent.Ok {
+					result = true
+				} else {
+					logEvent("*** Failed to send error message", requestFields(request, map[string]interface{}{"error": *sent.Description}))
+				}
+			}
+		} else if sendAs == sendAsPhoto || (len(sendAs) <= 0 && isPhoto(mime)) { // image type
+			b.SendChatAction(request.ChatID, bot.ChatActionUploadPhoto)
+
+			// burn annotation onto the image
+			if len(request.Annotation) > 0 {
+				if annotated, err := annotateImage(bytes, request.Annotation); err == nil {
+					bytes, output = annotated, annotated
+				} else {
+					logEvent("*** Failed to annotate image", requestFields(request, map[string]interface{}{"error": err.Error()}))
+				}
+			}
+
+			if sent := b.SendPhoto(request.ChatID, bot.InputFileFromBytes(bytes), optionsWithCaption(request.MessageOptions, caption)); sent.Ok {
+				result = true
+				succeeded = true
+			} else {
+				message := fmt.Sprintf("Failed to send photo: %s", *sent.Description)
+				logEvent(errorLogPrefix+message, requestFields(request, nil))
+
+				message = errorMessageForUser(request.UserID, message, "Failed to send photo.")
+
+				if sent := b.SendMessage(request.ChatID, message, request.MessageOptions); sent.Ok {
+					result = true
+				} else {
+					logEvent("*** Failed to send error message", requestFields(request, map[string]interface{}{"error": *sent.Description}))
+				}
+			}
+		} else if sendAs == sendAsVideo || (len(sendAs) <= 0 && strings.HasPrefix(mime, "video")) { // video type
+			b.SendChatAction(request.ChatID, bot.ChatActionUploadVideo)
+
+			if sent := b.SendVideo(request.ChatID, bot.InputFileFromBytes(bytes), optionsWithCaption(request.MessageOptions, caption)); sent.Ok {
+				result = true
+				succeeded = true
+			} else {
+				message := fmt.Sprintf("Failed to send video: %s", *sent.Description)
+				logEvent(errorLogPrefix+message, requestFields(request, nil))
+
+				message = errorMessageForUser(request.UserID, message, "Failed to send video.")
+
+				if sent := b.SendMessage(request.ChatID, message, request.MessageOptions); sent.Ok {
+					result = true
+				} else {
+					logEvent("*** Failed to send error message", requestFields(request, map[string]interface{}{"error": *sent.Description}))
+				}
+			}
+		} else if sendAs == sendAsAnimation || (len(sendAs) <= 0 && mime == mimeGIF) { // animation
+			b.SendChatAction(request.ChatID, bot.ChatActionUploadVideo)
+
+			if sent := b.SendAnimation(request.ChatID, bot.InputFileFromBytes(bytes), optionsWithCaption(request.MessageOptions, caption)); sent.Ok {
+				result = true
+				succeeded = true
+			} else if sent := sendDocumentWithFilename(b, request.ChatID, bytes, documentFilename(mime), optionsWithCaption(request.MessageOptions, caption)); sent.Ok { // (fall back to a document)
+				logEvent("*** Sent as a document, as sending animation failed", requestFields(request, nil))
+
+				result = true
+				succeeded = true
+			} else {
+				message := fmt.Sprintf("Failed to send animation: %s", *sent.Description)
+				logEvent(errorLogPrefix+message, requestFields(request, nil))
+
+				message = errorMessageForUser(request.UserID, message, "Failed to send animation.")
+
+				if sent := b.SendMessage(request.ChatID, message, request.MessageOptions); sent.Ok {
+					result = true
+				} else {
+					logEvent("*** Failed to send error message", requestFields(request, map[string]interface{}{"error": *sent.Description}))
+				}
+			}
+		} else if sendAs == sendAsDocument || (len(sendAs) <= 0 && (strings.HasPrefix(mime, "image") || // images which cannot be sent as photos
+			(!isPlainText(mime) && (utf8.Valid(bytes) || binaryOutputFallback == binaryFallbackDocument)) || // other types of documents, or binary
+			(isPlainText(mime) && utf8.Valid(bytes) && len(splitMessage(appendLine(string(bytes), caption), messageChunkBytes)) > maxMessageChunks))) { // text too long even for multiple messages
+			b.SendChatAction(request.ChatID, bot.ChatActionUploadDocument)
+
+			if sent := sendDocumentWithFilename(b, request.ChatID, bytes, documentFilename(mime), optionsWithCaption(request.MessageOptions, caption)); sent.Ok {
+				result = true
+				succeeded = true
+			} else {
+				message := fmt.Sprintf("Failed to send document: %s", *sent.Description)
+				logEvent(errorLogPrefix+message, requestFields(request, nil))
+
+				message = errorMessageForUser(request.UserID, message, "Failed to send document.")
+
+				if sent := b.SendMessage(request.ChatID, message, request.MessageOptions); sent.Ok {
+					result = true
+				} else {
+					logEvent("*** Failed to send error message", requestFields(request, map[string]interface{}{"error": *sent.Description}))
+				}
+			}
+		} else if script.Streaming && len(strings.TrimSpace(string(bytes))) <= 0 && len(caption) <= 0 { // (outputs were sent as frames)
+			result = true
+			succeeded = true
+		} else {
+			var message string
+			if utf8.Valid(bytes) {
+				message = string(bytes)
+			} else {
+				message = summarizeBinary(bytes, binaryOutputFallback)
+			}
+			message = appendLine(message, caption)
+			if len(strings.TrimSpace(message)) <= 0 {
+				message = messageNoOutput
+			}
+
+			// send long texts in multiple messages, in order
+			result = true
+			for _, chunk := range splitMessage(message, messageChunkBytes) {
+				if sent := b.SendMessage(request.ChatID, chunk, request.MessageOptions); !sent.Ok {
+					logEvent("*** Failed to send message", requestFields(request, map[string]interface{}{"error": *sent.Description}))
+
+					result = false
+					break
+				}
+			}
+			succeeded = result && (binaryOutputFallback != binaryFallbackError || utf8.Valid(bytes))
+		}
+	}
+
+	return result
+}
+
+// retrieve updates from API server constantly,
+// reading the monitor interval on every cycle so that it can be changed at runtime
+//
+// the offset (last update id + 1) is saved after each retrieval, so that handled updates are not retrieved again,
+// even after a restart
+//
+// (replaces bot.StartMonitoringUpdates which captures the interval)
+func monitorUpdates(b *bot.Bot, updateOffset int, updateHandler func(b *bot.Bot, update bot.Update, err error)) {
+	options := map[string]interface{}{
+		"offset": updateOffset,
+	}
+
+	for !isShuttingDown() {
+		if updates := b.GetUpdates(options); updates.Ok {
+			for _, update := range updates.Result {
+				// update offset (max + 1)
+				if options["offset"].(int) <= update.UpdateID {
+					options["offset"] = update.UpdateID + 1
+				}
+
+				go updateHandler(b, update, nil)
+			}
+			saveUpdateOffset(options["offset"].(int))
+		} else {
+			var description string
+			if updates.Description != nil {
+				description = *updates.Description
+			}
+			go updateHandler(b, bot.Update{}, fmt.Errorf("error while retrieving updates - %s", description))
+		}
+
+		time.Sleep(time.Duration(getMonitorInterval()) * time.Second)
+	}
+}
+
+// handle an update received with webhook or polling
+func handleUpdate(b BotClient, update bot.Update, err error) {
+	if err == nil {
+		if update.Message != nil {
+			processUpdate(b, update)
+		} else if update.CallbackQuery != nil {
+			processCallbackQuery(b, update)
+		}
+	} else {
+		log.Printf("*** Error while receiving update (%s)", err.Error())
+	}
+}
+
+func main() {
+	flag.Parse()
+	setup()
+
+	startTime = time.Now()
+
+	client := bot.NewClient(apiToken)
+	client.Verbose = isVerbose
+
+	// client for sending replies, retrying on transient failures
+	retrying := newRetryingClient(newAPIClient(client, apiToken))
+
+	// client for sending results, paced for group chats
+	paced := newPacedClient(retrying, time.Duration(groupSendIntervalMillis)*time.Millisecond)
+
+	// handler for updates received with webhook or polling
+	updateHandler := func(_ *bot.Bot, update bot.Update, err error) {
+		handleUpdate(retrying, update, err)
+	}
+
+	// get info about this bot
+	if me := client.GetMe(); me.Ok {
+		log.Printf("Launching bot: @%s (%s)", *me.Result.Username, me.Result.FirstName)
+
+		setBotUsername(*me.Result.Username)
+
+		// monitor execution request channel with workers (shared by both webhook and polling modes)
+		for i := 0; i < maxConcurrent; i++ {
+			go func() {
+				for {
+					select {
+					case request := <-executeChannel:
+						processExecuteRequest(paced, request) // request execution of the script
+					}
+				}
+			}()
+		}
+
+		// shut down gracefully on signals
+		go handleSignals(paced)
+
+		// run other bots in their own processes
+		if len(botConfigPaths) > 0 {
+			startChildBots(botConfigPaths)
+		}
+
+		// serve health checks
+		if healthPort > 0 {
+			startHealthServer(healthPort)
+		}
+
+		// run scheduled executions
+		startSchedules()
+
+		// send digests periodically
+		if len(digestTime) > 0 && digestChatID != 0 {
+			go runDigests(paced)
+		}
+
+		if webhook != nil {
+			// set webhook and wait for new updates
+			if hooked := client.SetWebhook(webhook.Host, webhook.Port, webhook.CertFilepath); hooked.Ok {
+				client.StartWebhookServerAndWait(webhook.CertFilepath, webhook.KeyFilepath, func(b *bot.Bot, update bot.Update, err error) {
+					go updateHandler(b, update, err)
+				})
+			} else {
+				panic("Failed to set webhook")
+			}
+		} else {
+			// delete webhook (getting updates will not work when wehbook is set up)
+			if unhooked := client.DeleteWebhook(); unhooked.Ok {
+				// wait for new updates
+				monitorUpdates(client, loadUpdateOffset(), updateHandler)
+
+				// (stopped for shutdown, wait for it to finish)
+				select {}
+			} else {
+				panic("Failed to delete webhook")
+			}
 		}
 	} else {
 		panic("Failed to get info of the bot")
@@ -0,0 +1,29 @@
+// maintenance mode, for rejecting executions without stopping the bot (eg. while moving the camera)
+
+package main
+
+import (
+	"log"
+	"sync/atomic"
+)
+
+// whether the bot is in maintenance mode
+var maintenanceMode atomic.Bool
+
+// check if the bot is in maintenance mode
+func isInMaintenance() bool {
+	return maintenanceMode.Load()
+}
+
+// turn maintenance mode on or off by given user
+func setMaintenance(on bool, userID string) {
+	if maintenanceMode.Swap(on) == on {
+		return // (not changed)
+	}
+
+	if on {
+		log.Printf("Maintenance mode turned on by %s", userID)
+	} else {
+		log.Printf("Maintenance mode turned off by %s", userID)
+	}
+}
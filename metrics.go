@@ -0,0 +1,122 @@
+// metrics of executions, exposed in prometheus text format
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	metricsPath = "/metrics"
+
+	metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+// upper bounds (in seconds) of the buckets of execution durations
+var durationBuckets = []float64{0.5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+
+// ExecutionKey struct for labels of executions
+type ExecutionKey struct {
+	Script string
+	Result string
+}
+
+// Metrics struct for metrics of executions
+type Metrics struct {
+	executions map[ExecutionKey]int64
+
+	bucketCounts  []int64 // cumulative counts, for each of durationBuckets
+	durationCount int64
+	durationSum   float64
+
+	sync.Mutex
+}
+
+// metrics of executions
+var metrics = Metrics{
+	executions:   map[ExecutionKey]int64{},
+	bucketCounts: make([]int64, len(durationBuckets)),
+}
+
+// record an execution of given script
+func (m *Metrics) record(script string, succeeded bool, duration time.Duration) {
+	m.Lock()
+	defer m.Unlock()
+
+	result := "succeeded"
+	if !succeeded {
+		result = "failed"
+	}
+	m.executions[ExecutionKey{Script: script, Result: result}]++
+
+	seconds := duration.Seconds()
+	for i, bound := range durationBuckets {
+		if seconds <= bound {
+			m.bucketCounts[i]++
+		}
+	}
+	m.durationCount++
+	m.durationSum += seconds
+}
+
+// escape given label value
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// write metrics to given writer in prometheus text format
+func (m *Metrics) write(w io.Writer) {
+	m.Lock()
+	defer m.Unlock()
+
+	// executions by script and result (sorted for stable outputs)
+	keys := []ExecutionKey{}
+	for key := range m.executions {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Script != keys[j].Script {
+			return keys[i].Script < keys[j].Script
+		}
+		return keys[i].Result < keys[j].Result
+	})
+	fmt.Fprintln(w, "# HELP bot_executions_total Number of executions by script and result.")
+	fmt.Fprintln(w, "# TYPE bot_executions_total counter")
+	for _, key := range keys {
+		fmt.Fprintf(w, "bot_executions_total{script=\"%s\",result=\"%s\"} %d\n", escapeLabel(key.Script), key.Result, m.executions[key])
+	}
+
+	// durations of executions
+	fmt.Fprintln(w, "# HELP bot_execution_duration_seconds Durations of executions.")
+	fmt.Fprintln(w, "# TYPE bot_execution_duration_seconds histogram")
+	for i, bound := range durationBuckets {
+		fmt.Fprintf(w, "bot_execution_duration_seconds_bucket{le=\"%g\"} %d\n", bound, m.bucketCounts[i])
+	}
+	fmt.Fprintf(w, "bot_execution_duration_seconds_bucket{le=\"+Inf\"} %d\n", m.durationCount)
+	fmt.Fprintf(w, "bot_execution_duration_seconds_sum %g\n", m.durationSum)
+	fmt.Fprintf(w, "bot_execution_duration_seconds_count %d\n", m.durationCount)
+
+	// depth of the queue
+	fmt.Fprintln(w, "# HELP bot_queue_depth Number of requests waiting in the queue.")
+	fmt.Fprintln(w, "# TYPE bot_queue_depth gauge")
+	fmt.Fprintf(w, "bot_queue_depth %d\n", atomic.LoadInt32(&queueLength))
+}
+
+// respond with the metrics
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", metricsContentType)
+
+	var b strings.Builder
+	metrics.write(&b)
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		log.Printf("*** Failed to write metrics: %s", err)
+	}
+}
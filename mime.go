@@ -0,0 +1,66 @@
+// detecting content types of outputs
+
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+)
+
+const (
+	mimeWebP = "image/webp"
+	mimeHEIC = "image/heic"
+	mimeAVIF = "image/avif"
+	mimeGIF  = "image/gif"
+)
+
+// brands of ISO base media files (in their 'ftyp' boxes) and their mime types
+var ftypBrands = map[string]string{
+	"heic": mimeHEIC,
+	"heix": mimeHEIC,
+	"heim": mimeHEIC,
+	"heis": mimeHEIC,
+	"hevc": mimeHEIC,
+	"hevx": mimeHEIC,
+	"mif1": mimeHEIC,
+	"msf1": mimeHEIC,
+	"avif": mimeAVIF,
+	"avis": mimeAVIF,
+}
+
+// image types which cannot be sent as photos (they will be sent as documents, or animations for GIFs)
+var nonPhotoImages = map[string]bool{
+	mimeHEIC: true,
+	mimeAVIF: true,
+	mimeGIF:  true,
+}
+
+// detect the content type of given bytes
+//
+// (also detects WebP, HEIC, and AVIF images, which can be missed by http.DetectContentType)
+func detectContentType(data []byte) string {
+	// GIF87a or GIF89a (sent as animations, not photos)
+	if bytes.HasPrefix(data, []byte("GIF87a")) || bytes.HasPrefix(data, []byte("GIF89a")) {
+		return mimeGIF
+	}
+
+	// RIFF....WEBP
+	if len(data) >= 12 && bytes.Equal(data[0:4], []byte("RIFF")) && bytes.Equal(data[8:12], []byte("WEBP")) {
+		return mimeWebP
+	}
+
+	// ....ftyp<brand>
+	if len(data) >= 12 && bytes.Equal(data[4:8], []byte("ftyp")) {
+		if mime, exists := ftypBrands[string(data[8:12])]; exists {
+			return mime
+		}
+	}
+
+	return http.DetectContentType(data)
+}
+
+// check if given mime type is of an image which can be sent as a photo
+func isPhoto(mime string) bool {
+	return strings.HasPrefix(mime, "image") && !nonPhotoImages[mime]
+}
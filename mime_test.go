@@ -0,0 +1,37 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDetectContentType(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  []byte
+		mime  string
+		photo bool
+	}{
+		{"png", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), "image/png", true},
+		{"jpeg", []byte("\xff\xd8\xff\xe0\x00\x10JFIF\x00"), "image/jpeg", true},
+		{"gif87a", []byte("GIF87a\x01\x00\x01\x00"), mimeGIF, false},
+		{"gif89a", []byte("GIF89a\x01\x00\x01\x00"), mimeGIF, false},
+		{"webp", []byte("RIFF\x24\x00\x00\x00WEBPVP8 "), mimeWebP, true},
+		{"heic", []byte("\x00\x00\x00\x18ftypheic\x00\x00\x00\x00"), mimeHEIC, false},
+		{"avif", []byte("\x00\x00\x00\x1cftypavif\x00\x00\x00\x00"), mimeAVIF, false},
+		{"unknown ftyp brand", []byte("\x00\x00\x00\x18ftypxxxx\x00\x00\x00\x00"), "application/octet-stream", false},
+		{"unknown binary", []byte("\x00\x01\x02\x03\x04\x05"), "application/octet-stream", false},
+		{"text", []byte("detected: 1 face"), "text/plain", false},
+		{"empty", []byte{}, "text/plain", false},
+	}
+
+	for _, test := range tests {
+		mime := detectContentType(test.data)
+		if !strings.HasPrefix(mime, test.mime) {
+			t.Errorf("%s: detectContentType() = %q, want %q", test.name, mime, test.mime)
+		}
+		if photo := isPhoto(mime); photo != test.photo {
+			t.Errorf("%s: isPhoto(%q) = %t, want %t", test.name, mime, photo, test.photo)
+		}
+	}
+}
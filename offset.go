@@ -0,0 +1,64 @@
+// persisting the offset of updates, for not replaying old updates after restarts
+
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultOffsetFilename = "offset"
+)
+
+// last saved offset (for skipping unchanged writes)
+var savedOffset int
+
+// default path of the offset file (next to given config file)
+func defaultOffsetPath(configFile string) string {
+	return defaultStatePath(configFile, defaultOffsetFilename)
+}
+
+// load the offset of updates from the file
+//
+// (returns 0 when it is missing or broken, for getting all pending updates)
+func loadUpdateOffset() int {
+	file, err := ioutil.ReadFile(offsetPath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("*** Failed to read offset: %s", err)
+		}
+		return 0
+	}
+
+	offset, err := strconv.Atoi(strings.TrimSpace(string(file)))
+	if err != nil {
+		log.Printf("*** Failed to parse offset: %s", err)
+		return 0
+	}
+	savedOffset = offset
+
+	return offset
+}
+
+// save the offset of updates to the file, if it was changed
+func saveUpdateOffset(offset int) {
+	if offset == savedOffset {
+		return
+	}
+
+	// write to a temporary file first, for not leaving a broken file behind
+	tmp := offsetPath + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(strconv.Itoa(offset)), 0600); err != nil {
+		log.Printf("*** Failed to write offset: %s", err)
+		return
+	}
+	if err := os.Rename(tmp, offsetPath); err != nil {
+		log.Printf("*** Failed to save offset: %s", err)
+		return
+	}
+	savedOffset = offset
+}
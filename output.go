@@ -0,0 +1,69 @@
+// limiting outputs of scripts
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+)
+
+const (
+	defaultMaxOutputBytes = 50 * 1024 * 1024 // max bytes of a script's output (= max size of files sent by bots)
+)
+
+// error for outputs exceeding the max size
+var errOutputTooLarge = errors.New("output too large")
+
+// LimitedBuffer struct for a buffer which stops growing at its limit
+type LimitedBuffer struct {
+	buffer   bytes.Buffer
+	limit    int
+	exceeded bool
+	onExceed func() // called once when the limit is exceeded
+	sync.Mutex
+}
+
+// create a buffer limited to given bytes (no limit when <= 0)
+func newLimitedBuffer(limit int, onExceed func()) *LimitedBuffer {
+	return &LimitedBuffer{
+		limit:    limit,
+		onExceed: onExceed,
+	}
+}
+
+// Write writes given bytes up to the limit, and discards the rest
+func (b *LimitedBuffer) Write(p []byte) (n int, err error) {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.limit > 0 && b.buffer.Len()+len(p) > b.limit {
+		b.buffer.Write(p[:b.limit-b.buffer.Len()])
+
+		if !b.exceeded {
+			b.exceeded = true
+			if b.onExceed != nil {
+				b.onExceed()
+			}
+		}
+		return len(p), nil
+	}
+
+	return b.buffer.Write(p)
+}
+
+// Bytes returns the written bytes
+func (b *LimitedBuffer) Bytes() []byte {
+	b.Lock()
+	defer b.Unlock()
+
+	return b.buffer.Bytes()
+}
+
+// Exceeded returns whether the limit was exceeded
+func (b *LimitedBuffer) Exceeded() bool {
+	b.Lock()
+	defer b.Unlock()
+
+	return b.exceeded
+}
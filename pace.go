@@ -0,0 +1,94 @@
+// pacing of sends to group chats
+
+package main
+
+import (
+	"sync"
+	"time"
+
+	bot "github.com/meinside/telegram-bot-go"
+)
+
+const (
+	defaultGroupSendIntervalMillis = 3000 // groups are limited to about 20 messages per minute
+)
+
+// PacedClient struct for spacing out sends to the same group chat, for avoiding flood limits
+type PacedClient struct {
+	BotClient
+
+	interval time.Duration
+	lastSent map[int64]time.Time
+	sync.Mutex
+}
+
+// create a client which paces sends of given client
+func newPacedClient(client BotClient, interval time.Duration) *PacedClient {
+	return &PacedClient{
+		BotClient: client,
+		interval:  interval,
+		lastSent:  map[int64]time.Time{},
+	}
+}
+
+// wait for the turn of given chat
+//
+// (only group chats, which have negative ids, are paced)
+func (c *PacedClient) wait(chatID bot.ChatID) {
+	id, ok := chatID.(int64)
+	if !ok || id >= 0 || c.interval <= 0 {
+		return
+	}
+
+	c.Lock()
+	next := time.Now()
+	if last, exists := c.lastSent[id]; exists && last.Add(c.interval).After(next) {
+		next = last.Add(c.interval)
+	}
+	c.lastSent[id] = next
+	c.Unlock()
+
+	time.Sleep(time.Until(next))
+}
+
+// SendMessage sends a message after waiting for its turn
+func (c *PacedClient) SendMessage(chatID bot.ChatID, text string, options bot.OptionsSendMessage) bot.APIResponseMessage {
+	c.wait(chatID)
+	return c.BotClient.SendMessage(chatID, text, options)
+}
+
+// SendPhoto sends a photo after waiting for its turn
+func (c *PacedClient) SendPhoto(chatID bot.ChatID, photo bot.InputFile, options bot.OptionsSendPhoto) bot.APIResponseMessage {
+	c.wait(chatID)
+	return c.BotClient.SendPhoto(chatID, photo, options)
+}
+
+// SendVideo sends a video after waiting for its turn
+func (c *PacedClient) SendVideo(chatID bot.ChatID, video bot.InputFile, options bot.OptionsSendVideo) bot.APIResponseMessage {
+	c.wait(chatID)
+	return c.BotClient.SendVideo(chatID, video, options)
+}
+
+// SendAnimation sends an animation after waiting for its turn
+func (c *PacedClient) SendAnimation(chatID bot.ChatID, animation bot.InputFile, options bot.OptionsSendAnimation) bot.APIResponseMessage {
+	c.wait(chatID)
+	return c.BotClient.SendAnimation(chatID, animation, options)
+}
+
+// SendDocument sends a document after waiting for its turn
+func (c *PacedClient) SendDocument(chatID bot.ChatID, document bot.InputFile, options bot.OptionsSendDocument) bot.APIResponseMessage {
+	c.wait(chatID)
+	return c.BotClient.SendDocument(chatID, document, options)
+}
+
+// SendMediaGroup sends a media group after waiting for its turn
+func (c *PacedClient) SendMediaGroup(chatID bot.ChatID, media []bot.InputMedia, options bot.OptionsSendMediaGroup) bot.APIResponseMessages {
+	c.wait(chatID)
+	return c.BotClient.SendMediaGroup(chatID, media, options)
+}
+
+// SetMessageReaction reacts to a message after waiting for its turn
+func (c *PacedClient) SetMessageReaction(chatID bot.ChatID, messageID int, emoji string) bot.APIResponseBool {
+	c.wait(chatID)
+	return c.BotClient.SetMessageReaction(chatID, messageID, emoji)
+}
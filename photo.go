@@ -0,0 +1,72 @@
+// photos uploaded as inputs of scripts
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"time"
+
+	bot "github.com/meinside/telegram-bot-go"
+)
+
+const (
+	inputPhotoPattern        = "input-*.jpg"
+	inputPhotoTimeoutSeconds = 60
+)
+
+// http client for downloading input photos
+var inputHTTPClient = &http.Client{
+	Timeout: inputPhotoTimeoutSeconds * time.Second,
+}
+
+// text of given message, or the caption of its photo
+func messageText(message *bot.Message) (txt string, exists bool) {
+	if message.HasText() {
+		return *message.Text, true
+	}
+	if message.HasPhoto() && message.HasCaption() {
+		return *message.Caption, true
+	}
+	return "", false
+}
+
+// download the photo with given file id to a temporary file, and return its path
+//
+// (the file should be removed by the caller)
+func downloadInputPhoto(b BotClient, fileID string) (path string, err error) {
+	file := b.GetFile(fileID)
+	if !file.Ok || file.Result == nil || file.Result.FilePath == nil {
+		var description string
+		if file.Description != nil {
+			description = *file.Description
+		}
+		return "", fmt.Errorf("failed to get file: %s", description)
+	}
+
+	resp, err := inputHTTPClient.Get(b.GetFileURL(*file.Result))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to download file: %s", resp.Status)
+	}
+
+	tmp, err := ioutil.TempFile(tempDir, inputPhotoPattern)
+	if err != nil {
+		return "", err
+	}
+	defer tmp.Close()
+
+	if _, err := io.Copy(tmp, resp.Body); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+
+	return tmp.Name(), nil
+}
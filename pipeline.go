@@ -0,0 +1,67 @@
+// pipelines of scripts, with outputs piped to inputs of the next ones
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	pipelineSeparator = "|"
+	maxPipelineStages = 5
+)
+
+// parse a pipeline of script names, eg. "capture|detect|annotate"
+func parsePipeline(text string) (stages []string, err error) {
+	for _, name := range strings.Split(text, pipelineSeparator) {
+		name = strings.TrimSpace(name)
+		if len(name) <= 0 {
+			return nil, fmt.Errorf("empty stage in pipeline")
+		}
+		if _, exists := scripts[name]; !exists {
+			return nil, fmt.Errorf("no such script: %s", name)
+		}
+		stages = append(stages, name)
+	}
+
+	if len(stages) < 2 {
+		return nil, fmt.Errorf("a pipeline needs at least 2 scripts")
+	}
+	if len(stages) > maxPipelineStages {
+		return nil, fmt.Errorf("too many scripts in pipeline (max: %d)", maxPipelineStages)
+	}
+
+	// (all stages run under the lock of a single device)
+	for _, name := range stages[1:] {
+		if scripts[name].Device != scripts[stages[0]].Device {
+			return nil, fmt.Errorf("scripts in a pipeline should use the same device")
+		}
+	}
+
+	return stages, nil
+}
+
+// run scripts of given pipeline in sequence, piping the output of each stage into the input of the next one
+//
+// returns the output of the final stage, or an error with the failed stage
+func runPipeline(stages []string, execution *RunningExecution) (stdout, stderr []byte, err error) {
+	for i, name := range stages {
+		script := scripts[name]
+
+		removeOutputFile(script.OutputFile)
+		command, args := script.command(script.Path, nil)
+		stdout, stderr, err = runScript(command, args, stdout, script.environment(), script.workDir(script.Path), script.timeout(), script.MemoryLimitMB, script.CPULimitSeconds, execution)
+		if err == nil && len(script.OutputFile) > 0 {
+			stdout, err = readOutputFile(script.OutputFile)
+		}
+		if err != nil {
+			return stdout, stderr, fmt.Errorf("stage %d (%s) failed: %s", i+1, name, err)
+		}
+		if execution.wasCancelled() {
+			return stdout, stderr, nil
+		}
+	}
+
+	return stdout, stderr, nil
+}
@@ -0,0 +1,26 @@
+//go:build linux
+// +build linux
+
+// process groups of scripts (linux only)
+
+package main
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// run given command in its own process group, so that its children can be signaled together
+//
+// (when its context is done, the whole group is killed, not only the direct child)
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
+
+// send SIGTERM to the process group of a started command
+func terminateProcessGroup(cmd *exec.Cmd) error {
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+}
@@ -0,0 +1,41 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimedOutScriptIsKilledWithItsChildren(t *testing.T) {
+	// (the child keeps stdout open, so waiting would block until it exits, if it were not killed together)
+	path := writeTestScript(t, "sleep 30 &\nsleep 30")
+
+	startedAt := time.Now()
+	_, _, err := runScript(path, nil, nil, nil, "", 500*time.Millisecond, 0, 0, nil)
+	if err != errScriptTimedOut {
+		t.Errorf("expected the script to time out, got: %v", err)
+	}
+	if elapsed := time.Since(startedAt); elapsed > 3*time.Second {
+		t.Errorf("expected the script and its children to be killed on timeout, but took %s", elapsed)
+	}
+}
+
+func TestScriptWithTooLargeOutputIsKilledWithItsChildren(t *testing.T) {
+	saved := maxOutputBytes
+	t.Cleanup(func() { maxOutputBytes = saved })
+	maxOutputBytes = 1024
+
+	// (output is printed by a child, not by the script itself)
+	path := writeTestScript(t, "yes &\nwait")
+
+	startedAt := time.Now()
+	_, _, err := runScript(path, nil, nil, nil, "", 30*time.Second, 0, 0, nil)
+	if err != errOutputTooLarge {
+		t.Errorf("expected the output to be too large, got: %v", err)
+	}
+	if elapsed := time.Since(startedAt); elapsed > 3*time.Second {
+		t.Errorf("expected the script and its children to be killed on too large output, but took %s", elapsed)
+	}
+}
@@ -0,0 +1,19 @@
+//go:build !linux
+// +build !linux
+
+// process groups of scripts (not supported on platforms other than linux)
+
+package main
+
+import (
+	"os/exec"
+)
+
+// do nothing on this platform
+func setProcessGroup(cmd *exec.Cmd) {
+}
+
+// kill the process of a started command (its children are not signaled on this platform)
+func terminateProcessGroup(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
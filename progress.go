@@ -0,0 +1,183 @@
+// progress of scripts, shown with a live-updating message
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	progressMarker       = "PROGRESS:"     // prefix of progress lines, eg. "PROGRESS: 40%"
+	progressEditInterval = 2 * time.Second // min interval between edits of the progress message
+	maxProgressLineBytes = 64              // lines longer than this are not progress lines
+)
+
+// pattern of progress lines
+var progressPattern = regexp.MustCompile(`^` + progressMarker + `\s*(\d{1,3})%\s*$`)
+
+// ProgressWriter struct for picking progress lines out of a script's output
+//
+// (progress lines are recognized only before the result, and the rest is written to the output as it is)
+type ProgressWriter struct {
+	output      io.Writer
+	pending     []byte // incomplete line
+	passthrough bool   // whether the result has started
+	onProgress  func(percent int)
+}
+
+// create a writer which picks progress lines out before writing to given output
+func newProgressWriter(output io.Writer, onProgress func(percent int)) *ProgressWriter {
+	return &ProgressWriter{
+		output:     output,
+		onProgress: onProgress,
+	}
+}
+
+// Write picks progress lines out of given bytes, and writes the rest to the output
+func (w *ProgressWriter) Write(p []byte) (n int, err error) {
+	if w.passthrough {
+		return w.output.Write(p)
+	}
+
+	w.pending = append(w.pending, p...)
+	for !w.passthrough && len(w.pending) > 0 {
+		// not a progress line
+		if !bytes.HasPrefix(w.pending, []byte(progressMarker)) && !bytes.HasPrefix([]byte(progressMarker), w.pending) {
+			w.passthrough = true
+			break
+		}
+
+		// wait for the rest of the line
+		i := bytes.IndexByte(w.pending, '\n')
+		if i < 0 {
+			if len(w.pending) > maxProgressLineBytes {
+				w.passthrough = true
+				break
+			}
+			return len(p), nil
+		}
+
+		matches := progressPattern.FindSubmatch(bytes.TrimRight(w.pending[:i], "\r"))
+		if matches == nil {
+			w.passthrough = true
+			break
+		}
+
+		percent, _ := strconv.Atoi(string(matches[1]))
+		w.onProgress(percent)
+
+		w.pending = w.pending[i+1:]
+	}
+
+	if w.passthrough && len(w.pending) > 0 {
+		pending := w.pending
+		w.pending = nil
+		if _, err := w.output.Write(pending); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
+
+// flush writes the incomplete line (if any) to the output
+func (w *ProgressWriter) flush() {
+	if len(w.pending) > 0 {
+		w.output.Write(w.pending)
+		w.pending = nil
+	}
+}
+
+// ProgressReporter struct for showing the progress of a script with a message, which is edited on changes
+type ProgressReporter struct {
+	b       BotClient
+	chatID  interface{}
+	options map[string]interface{}
+
+	percent   int // latest reported percent (-1 for none)
+	messageID int // id of the progress message (0 when not sent yet)
+	stop      chan struct{}
+	done      chan struct{}
+	sync.Mutex
+}
+
+// start showing progresses in given chat
+func startProgressReporter(b BotClient, chatID interface{}, options map[string]interface{}) *ProgressReporter {
+	r := &ProgressReporter{
+		b:       b,
+		chatID:  chatID,
+		options: options,
+		percent: -1,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go r.run()
+
+	return r
+}
+
+// report the latest progress
+func (r *ProgressReporter) update(percent int) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.percent = percent
+}
+
+// send or edit the progress message periodically (debounced for avoiding rate limits)
+func (r *ProgressReporter) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(progressEditInterval)
+	defer ticker.Stop()
+
+	shown := -1
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			r.Lock()
+			percent := r.percent
+			r.Unlock()
+
+			if percent < 0 || percent == shown {
+				continue
+			}
+			shown = percent
+
+			message := fmt.Sprintf(messageProgressFormat, percent)
+			if r.messageID == 0 {
+				if sent := r.b.SendMessage(r.chatID, message, r.options); sent.Ok {
+					r.messageID = sent.Result.MessageID
+				} else {
+					log.Printf("*** Failed to send progress: %s", *sent.Description)
+				}
+			} else if edited := r.b.EditMessageText(message, map[string]interface{}{
+				"chat_id":    r.chatID,
+				"message_id": r.messageID,
+			}); !edited.Ok {
+				log.Printf("*** Failed to edit progress: %s", *edited.Description)
+			}
+		}
+	}
+}
+
+// stop showing progresses, and remove the progress message (to be replaced with the result)
+func (r *ProgressReporter) finish() {
+	close(r.stop)
+	<-r.done
+
+	if r.messageID != 0 {
+		if deleted := r.b.DeleteMessage(r.chatID, r.messageID); !deleted.Ok {
+			log.Printf("*** Failed to delete progress: %s", *deleted.Description)
+		}
+	}
+}
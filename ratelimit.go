@@ -0,0 +1,38 @@
+// per-user rate limiting of executions
+
+package main
+
+import (
+	"time"
+)
+
+// TokenBucket struct for rate limiting (refilled continuously, up to its capacity)
+type TokenBucket struct {
+	Tokens     float64
+	LastRefill time.Time
+}
+
+// take a token from the bucket which is refilled with given number of tokens per minute
+//
+// returns the updated bucket, and the time to wait when no token is available
+func (b TokenBucket) take(perMinute int, now time.Time) (TokenBucket, time.Duration) {
+	capacity := float64(perMinute)
+	rate := capacity / time.Minute.Seconds() // tokens per second
+
+	if b.LastRefill.IsZero() {
+		b.Tokens = capacity
+	} else {
+		b.Tokens += now.Sub(b.LastRefill).Seconds() * rate
+		if b.Tokens > capacity {
+			b.Tokens = capacity
+		}
+	}
+	b.LastRefill = now
+
+	if b.Tokens < 1 {
+		return b, time.Duration((1 - b.Tokens) / rate * float64(time.Second))
+	}
+	b.Tokens--
+
+	return b, 0
+}
@@ -0,0 +1,67 @@
+// reactions to messages
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	bot "github.com/meinside/telegram-bot-go"
+)
+
+// APIClient struct for calling the bot API with the bot library,
+// and the methods which are not supported by it yet
+type APIClient struct {
+	*bot.Bot
+
+	token string
+}
+
+// create a client with given bot and its token
+func newAPIClient(client *bot.Bot, token string) *APIClient {
+	return &APIClient{
+		Bot:   client,
+		token: token,
+	}
+}
+
+// SetMessageReaction reacts to a message with given emoji
+//
+// (not supported by the bot library, so requested directly)
+//
+// https://core.telegram.org/bots/api#setmessagereaction
+func (c *APIClient) SetMessageReaction(chatID bot.ChatID, messageID int, emoji string) (result bot.APIResponseBool) {
+	params, _ := json.Marshal(map[string]interface{}{
+		"chat_id":    chatID,
+		"message_id": messageID,
+		"reaction": []map[string]string{
+			{"type": "emoji", "emoji": emoji},
+		},
+	})
+
+	resp, err := http.Post(fmt.Sprintf("%s%s/setMessageReaction", telegramAPIBaseURL, c.token), "application/json", bytes.NewReader(params))
+	if err != nil {
+		description := fmt.Sprintf("failed to request: %s", err)
+		return bot.APIResponseBool{APIResponseBase: bot.APIResponseBase{Description: &description}}
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		description := fmt.Sprintf("failed to read response: %s", err)
+		return bot.APIResponseBool{APIResponseBase: bot.APIResponseBase{Description: &description}}
+	}
+	return result
+}
+
+// react to a message with given emoji
+func setMessageReaction(b BotClient, chatID bot.ChatID, messageID int, emoji string) bool {
+	res := b.SetMessageReaction(chatID, messageID, emoji)
+	if !res.Ok && res.Description != nil {
+		log.Printf("*** Failed to set reaction: %s", *res.Description)
+	}
+
+	return res.Ok
+}
@@ -0,0 +1,108 @@
+// reloading config without restarting
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+)
+
+// for allowed ids which can be changed by reloading
+var allowedIdsLock sync.Mutex
+
+// re-read the config file, and apply allowed (user) ids, script path, and monitor interval
+//
+// sessions of newly allowed users are created, and those of removed users are dropped
+func reloadConfig() (added, removed int, err error) {
+	config, err := getConfig()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if err := validateScriptPaths(config.ScriptPath, "", nil); err != nil {
+		return 0, 0, err
+	}
+
+	ids := config.AllowedIds
+	if len(config.AllowedIdsFile) > 0 {
+		fileIds, err := readIdsFile(config.AllowedIdsFile)
+		if err != nil {
+			return 0, 0, err
+		}
+		ids = mergeIds(ids, fileIds)
+	}
+
+	// (sessions and the default script path are read by command handlers and queue workers while holding pool's lock)
+	pool.Lock()
+
+	// session ids of usernames and numeric user ids
+	sessionIds := append([]string{}, ids...)
+	for _, id := range config.AllowedUserIDs {
+		sessionIds = append(sessionIds, numericSessionID(id))
+	}
+
+	allowed := map[string]bool{}
+	for _, id := range sessionIds {
+		allowed[id] = true
+
+		if _, exists := pool.Sessions[id]; !exists {
+			pool.Sessions[id] = Session{
+				UserID:        id,
+				CurrentStatus: StatusWaiting,
+			}
+			added++
+		}
+	}
+	for id := range pool.Sessions {
+		if !allowed[id] {
+			delete(pool.Sessions, id)
+			removed++
+		}
+	}
+
+	saveSessions()
+
+	scriptPath = config.ScriptPath
+
+	allowedIdsLock.Lock()
+	allowedIds = ids
+	allowedUserIDs = config.AllowedUserIDs
+	allowedIdsLock.Unlock()
+
+	pool.Unlock()
+
+	setMonitorInterval(intOrDefault(config.MonitorInterval, defaultMonitorIntervalSeconds))
+
+	// reflect reloaded values only (others are not applied until restart)
+	currentConfigLock.Lock()
+	currentConfig.AllowedIds = config.AllowedIds
+	currentConfig.AllowedIdsFile = config.AllowedIdsFile
+	currentConfig.AllowedUserIDs = config.AllowedUserIDs
+	currentConfig.ScriptPath = config.ScriptPath
+	currentConfig.MonitorInterval = config.MonitorInterval
+	currentConfigLock.Unlock()
+
+	log.Printf("Reloaded config: %d user(s) added, %d user(s) removed", added, removed)
+
+	return added, removed, nil
+}
+
+// reload config and report the result
+func processReload(b BotClient, chatID int64, options map[string]interface{}) bool {
+	var message string
+	if added, removed, err := reloadConfig(); err == nil {
+		message = fmt.Sprintf(messageReloadedFormat, added, removed)
+	} else {
+		log.Printf("*** Failed to reload config: %s", err)
+
+		message = fmt.Sprintf(messageErrorFormat, err)
+	}
+
+	sent := b.SendMessage(chatID, message, options)
+	if !sent.Ok {
+		log.Printf("*** Failed to send message: %s", *sent.Description)
+	}
+
+	return sent.Ok
+}
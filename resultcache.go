@@ -0,0 +1,59 @@
+// cache of results of scripts, for serving the same requests without running scripts again
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// results of scripts, keyed by scripts and their arguments
+var resultCache = OutputCache{
+	outputs:    map[string]CachedOutput{},
+	ttlSeconds: &resultCacheTTLSeconds,
+}
+
+// key of given request in the result cache
+//
+// returns false when the result of given request should not be cached
+func resultCacheKey(request ExecuteRequest) (key string, cacheable bool) {
+	if resultCacheTTLSeconds <= 0 ||
+		len(request.Pipeline) > 0 ||
+		len(request.InputFileID) > 0 ||
+		len(request.Annotation) > 0 ||
+		request.ClipSeconds > 0 ||
+		scripts[request.ScriptName].Streaming {
+		return "", false
+	}
+
+	return strings.Join(append([]string{valueOrDefault(request.ScriptName, request.ScriptPath)}, request.Args...), "\x00"), true
+}
+
+// send the cached result of given request (if any)
+//
+// returns true when it was found and sent
+func sendCachedResult(b BotClient, request ExecuteRequest) bool {
+	key, cacheable := resultCacheKey(request)
+	if !cacheable {
+		return false
+	}
+
+	output, exists := resultCache.get(key)
+	if !exists {
+		return false
+	}
+
+	logEvent("Serving cached result", requestFields(request, nil))
+
+	output.Caption = appendLine(output.Caption, fmt.Sprintf(messageCachedFormat, int(time.Since(output.CachedAt).Seconds())))
+
+	return resendOutput(b, request.ChatID, output, request.MessageOptions)
+}
+
+// cache the result of given request
+func cacheResult(request ExecuteRequest, bytes []byte, mime, caption string) {
+	if key, cacheable := resultCacheKey(request); cacheable && len(bytes) > 0 {
+		resultCache.store(key, bytes, mime, caption)
+	}
+}
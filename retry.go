@@ -0,0 +1,161 @@
+// retries of sends for transient failures
+
+package main
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	bot "github.com/meinside/telegram-bot-go"
+)
+
+const (
+	defaultSendRetries = 3 // number of retries for failed sends
+
+	sendRetryBackoff    = 1 * time.Second  // backoff before the first retry (doubled on each retry)
+	maxSendRetryBackoff = 30 * time.Second // max backoff between retries
+
+	unparsableEntitiesError = "can't parse entities" // part of the error description for texts with broken formatting
+)
+
+// prefixes of error descriptions which will not be fixed by retrying
+var permanentSendErrors = []string{
+	"Bad Request",
+	"Unauthorized",
+	"Forbidden",
+	"Not Found",
+}
+
+// check if given failed response is worth retrying
+func isTransientFailure(response bot.APIResponseBase) bool {
+	if response.Parameters != nil && response.Parameters.RetryAfter > 0 {
+		return true
+	}
+	if response.Description != nil {
+		for _, prefix := range permanentSendErrors {
+			if strings.HasPrefix(*response.Description, prefix) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// call given send function, and retry it with exponential backoff on transient failures
+//
+// (honors `retry_after` of the response when it is given)
+func sendWithRetry(send func() bot.APIResponseBase) {
+	backoff := sendRetryBackoff
+	for i := 0; ; i++ {
+		response := send()
+		if response.Ok || i >= sendRetries || !isTransientFailure(response) {
+			return
+		}
+
+		wait := backoff
+		if response.Parameters != nil && response.Parameters.RetryAfter > 0 {
+			wait = time.Duration(response.Parameters.RetryAfter) * time.Second
+		}
+
+		var description string
+		if response.Description != nil {
+			description = *response.Description
+		}
+		log.Printf("*** Failed to send (%s), retrying in %s (%d/%d)", description, wait, i+1, sendRetries)
+
+		time.Sleep(wait)
+
+		if backoff *= 2; backoff > maxSendRetryBackoff {
+			backoff = maxSendRetryBackoff
+		}
+	}
+}
+
+// RetryingClient struct for retrying failed sends of given client
+type RetryingClient struct {
+	BotClient
+}
+
+// create a client which retries failed sends of given client
+func newRetryingClient(client BotClient) *RetryingClient {
+	return &RetryingClient{
+		BotClient: client,
+	}
+}
+
+// SendMessage sends a message, with retries
+//
+// (when the text could not be parsed with its parse mode, it is sent again without formatting)
+func (c *RetryingClient) SendMessage(chatID bot.ChatID, text string, options bot.OptionsSendMessage) (result bot.APIResponseMessage) {
+	sendWithRetry(func() bot.APIResponseBase {
+		result = c.BotClient.SendMessage(chatID, text, options)
+		return result.APIResponseBase
+	})
+
+	if _, formatted := options["parse_mode"]; formatted && !result.Ok && result.Description != nil && strings.Contains(*result.Description, unparsableEntitiesError) {
+		unformatted := bot.OptionsSendMessage{}
+		for k, v := range options {
+			if k != "parse_mode" {
+				unformatted[k] = v
+			}
+		}
+		return c.SendMessage(chatID, text, unformatted)
+	}
+
+	return result
+}
+
+// SendPhoto sends a photo, with retries
+func (c *RetryingClient) SendPhoto(chatID bot.ChatID, photo bot.InputFile, options bot.OptionsSendPhoto) (result bot.APIResponseMessage) {
+	sendWithRetry(func() bot.APIResponseBase {
+		result = c.BotClient.SendPhoto(chatID, photo, options)
+		return result.APIResponseBase
+	})
+	return result
+}
+
+// SendVideo sends a video, with retries
+func (c *RetryingClient) SendVideo(chatID bot.ChatID, video bot.InputFile, options bot.OptionsSendVideo) (result bot.APIResponseMessage) {
+	sendWithRetry(func() bot.APIResponseBase {
+		result = c.BotClient.SendVideo(chatID, video, options)
+		return result.APIResponseBase
+	})
+	return result
+}
+
+// SendAnimation sends an animation, with retries
+func (c *RetryingClient) SendAnimation(chatID bot.ChatID, animation bot.InputFile, options bot.OptionsSendAnimation) (result bot.APIResponseMessage) {
+	sendWithRetry(func() bot.APIResponseBase {
+		result = c.BotClient.SendAnimation(chatID, animation, options)
+		return result.APIResponseBase
+	})
+	return result
+}
+
+// SendDocument sends a document, with retries
+func (c *RetryingClient) SendDocument(chatID bot.ChatID, document bot.InputFile, options bot.OptionsSendDocument) (result bot.APIResponseMessage) {
+	sendWithRetry(func() bot.APIResponseBase {
+		result = c.BotClient.SendDocument(chatID, document, options)
+		return result.APIResponseBase
+	})
+	return result
+}
+
+// SendMediaGroup sends a media group, with retries
+func (c *RetryingClient) SendMediaGroup(chatID bot.ChatID, media []bot.InputMedia, options bot.OptionsSendMediaGroup) (result bot.APIResponseMessages) {
+	sendWithRetry(func() bot.APIResponseBase {
+		result = c.BotClient.SendMediaGroup(chatID, media, options)
+		return result.APIResponseBase
+	})
+	return result
+}
+
+// SetMessageReaction reacts to a message, with retries
+func (c *RetryingClient) SetMessageReaction(chatID bot.ChatID, messageID int, emoji string) (result bot.APIResponseBool) {
+	sendWithRetry(func() bot.APIResponseBase {
+		result = c.BotClient.SetMessageReaction(chatID, messageID, emoji)
+		return result.APIResponseBase
+	})
+	return result
+}
@@ -0,0 +1,50 @@
+//go:build linux
+// +build linux
+
+// resource limits of scripts (linux only)
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+)
+
+const resourceLimitsShell = "/bin/sh"
+
+// wrap given command with a shell which sets resource limits and then execs the command
+//
+// (os/exec does not support setting rlimits of child processes, and setting them after start
+// would leave a window in which the script runs without them)
+func withResourceLimits(path string, args []string, memoryLimitMB, cpuLimitSeconds int) (string, []string, error) {
+	if memoryLimitMB <= 0 && cpuLimitSeconds <= 0 {
+		return path, args, nil
+	}
+
+	lines := []string{"set -e"}
+	if memoryLimitMB > 0 {
+		lines = append(lines, fmt.Sprintf("ulimit -v %d", memoryLimitMB*1024)) // (in KB)
+	}
+	if cpuLimitSeconds > 0 {
+		// SIGXCPU on soft limit, and SIGKILL on hard limit
+		lines = append(lines, fmt.Sprintf("ulimit -t %d", cpuLimitSeconds+1), fmt.Sprintf("ulimit -S -t %d", cpuLimitSeconds))
+	}
+	lines = append(lines, `exec "$0" "$@"`)
+
+	// (the command and its arguments are passed as positional parameters, not to be interpreted by the shell)
+	return resourceLimitsShell, append([]string{"-c", strings.Join(lines, "\n"), path}, args...), nil
+}
+
+// check if given process was killed for exceeding its resource limits
+func exceededResourceLimits(state *os.ProcessState) bool {
+	if state == nil {
+		return false
+	}
+
+	if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return status.Signal() == syscall.SIGXCPU || status.Signal() == syscall.SIGKILL
+	}
+	return false
+}
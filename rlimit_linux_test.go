@@ -0,0 +1,39 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResourceLimitsAreAppliedBeforeScriptStarts(t *testing.T) {
+	// (prints limits the script started with)
+	path := writeTestScript(t, `ulimit -S -v; ulimit -S -t; ulimit -H -t; echo "$@"`)
+
+	stdout, stderr, err := runScript(path, []string{"a b", "$HOME"}, nil, nil, "", 10*time.Second, 64, 5, nil)
+	if err != nil {
+		t.Fatalf("failed to run script: %s (%s)", err, stderr)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(stdout)), "\n"); len(lines) != 4 ||
+		lines[0] != "65536" || lines[1] != "5" || lines[2] != "6" || lines[3] != "a b $HOME" {
+		t.Errorf("expected limits and arguments to be passed as they are, got: %q", stdout)
+	}
+}
+
+func TestScriptExceedingCPULimitIsKilled(t *testing.T) {
+	path := writeTestScript(t, `while :; do :; done`)
+
+	_, _, err := runScript(path, nil, nil, nil, "", 10*time.Second, 0, 1, nil)
+	if err == nil || !strings.Contains(err.Error(), "exceeding resource limits") {
+		t.Errorf("expected the script to be killed for exceeding its cpu limit, got: %v", err)
+	}
+}
+
+func TestScriptWithoutResourceLimitsIsNotWrapped(t *testing.T) {
+	if path, args, err := withResourceLimits("/path/to/script", []string{"arg"}, 0, 0); err != nil || path != "/path/to/script" || len(args) != 1 {
+		t.Errorf("expected the command as it is, got: %s %q (%v)", path, args, err)
+	}
+}
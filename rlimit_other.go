@@ -0,0 +1,24 @@
+//go:build !linux
+// +build !linux
+
+// resource limits of scripts (not supported on platforms other than linux)
+
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// return given command as it is (fails when resource limits are given)
+func withResourceLimits(path string, args []string, memoryLimitMB, cpuLimitSeconds int) (string, []string, error) {
+	if memoryLimitMB > 0 || cpuLimitSeconds > 0 {
+		return path, args, fmt.Errorf("resource limits are not supported on this platform")
+	}
+	return path, args, nil
+}
+
+// check if given process was killed for exceeding its resource limits
+func exceededResourceLimits(state *os.ProcessState) bool {
+	return false
+}
@@ -0,0 +1,66 @@
+// roles of users, for different welcome messages and keyboards
+
+package main
+
+import (
+	"fmt"
+
+	bot "github.com/meinside/telegram-bot-go"
+)
+
+const (
+	roleAdmin = "admin" // users in `admin_ids`
+	roleGuest = "guest" // other allowed users
+)
+
+// Role struct for the welcome message and keyboard of users with a role
+type Role struct {
+	Welcome  string     `json:"welcome,omitempty"`  // reply to /start
+	Keyboard [][]string `json:"keyboard,omitempty"` // rows of commands or script names
+}
+
+// configured roles
+var roles map[string]Role
+
+// keyboards of roles (built from their configs)
+var roleKeyboards = map[string][][]bot.KeyboardButton{}
+
+// build keyboards of given roles
+func buildRoleKeyboards(roles map[string]Role, scripts map[string]Script) (map[string][][]bot.KeyboardButton, error) {
+	keyboards := map[string][][]bot.KeyboardButton{}
+	for name, role := range roles {
+		if name != roleAdmin && name != roleGuest {
+			return nil, fmt.Errorf("no such role: '%s' (%s or %s)", name, roleAdmin, roleGuest)
+		}
+
+		if len(role.Keyboard) > 0 {
+			keyboard, err := buildKeyboards(role.Keyboard, scripts)
+			if err != nil {
+				return nil, fmt.Errorf("role '%s': %s", name, err)
+			}
+			keyboards[name] = keyboard
+		}
+	}
+	return keyboards, nil
+}
+
+// role of given user
+func roleOf(userID string) string {
+	if isAdminID(userID) {
+		return roleAdmin
+	}
+	return roleGuest
+}
+
+// keyboard for given user (`keyboard` when not configured for the user's role)
+func keyboardOf(userID string) [][]bot.KeyboardButton {
+	if keyboard, exists := roleKeyboards[roleOf(userID)]; exists {
+		return keyboard
+	}
+	return allKeyboards
+}
+
+// welcome message for given user
+func welcomeOf(userID string) string {
+	return valueOrDefault(roles[roleOf(userID)].Welcome, messageDefault)
+}
@@ -0,0 +1,140 @@
+// scheduled executions of scripts
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	defaultMinScheduleIntervalSeconds = 60
+	defaultMaxSchedules               = 10
+
+	scheduleUserID = "(schedule)" // user id of scheduled requests
+)
+
+// Schedule struct for a periodic execution of a script
+//
+// (either `interval_seconds` or `cron` should be given)
+type Schedule struct {
+	Script          string `json:"script"` // name of the script in `scripts`
+	IntervalSeconds int    `json:"interval_seconds,omitempty"`
+	Cron            string `json:"cron,omitempty"` // eg. "0 7 * * *" for every morning at 7
+	ChatID          int64  `json:"chat_id"`        // chat to send results to
+}
+
+// describe when given schedule runs
+func (s Schedule) describe() string {
+	if len(s.Cron) > 0 {
+		return fmt.Sprintf("cron '%s'", s.Cron)
+	}
+	return fmt.Sprintf("every %ds", s.IntervalSeconds)
+}
+
+// the next time of given schedule after given time
+//
+// (returns a zero time when there is no next time)
+func (s Schedule) next(after time.Time) time.Time {
+	if len(s.Cron) > 0 {
+		if cron, err := parseCron(s.Cron); err == nil {
+			return cron.next(after)
+		}
+		return time.Time{}
+	}
+	return after.Add(time.Duration(s.IntervalSeconds) * time.Second)
+}
+
+// validate schedules and report all errors together
+func validateSchedules(schedules []Schedule, scripts map[string]Script, minIntervalSeconds, maxSchedules int) error {
+	errors := []string{}
+
+	if len(schedules) > maxSchedules {
+		errors = append(errors, fmt.Sprintf("too many schedules: %d (max: %d)", len(schedules), maxSchedules))
+	}
+	for i, schedule := range schedules {
+		if _, exists := scripts[schedule.Script]; !exists {
+			errors = append(errors, fmt.Sprintf("schedule #%d: no such script: '%s'", i+1, schedule.Script))
+		}
+		if len(schedule.Cron) > 0 {
+			if schedule.IntervalSeconds != 0 {
+				errors = append(errors, fmt.Sprintf("schedule #%d: both interval and cron are given", i+1))
+			}
+			if _, err := parseCron(schedule.Cron); err != nil {
+				errors = append(errors, fmt.Sprintf("schedule #%d: invalid cron expression '%s': %s", i+1, schedule.Cron, err))
+			}
+		} else if schedule.IntervalSeconds < minIntervalSeconds {
+			errors = append(errors, fmt.Sprintf("schedule #%d: interval too short: %ds (min: %ds)", i+1, schedule.IntervalSeconds, minIntervalSeconds))
+		}
+		if schedule.ChatID == 0 {
+			errors = append(errors, fmt.Sprintf("schedule #%d: no chat id", i+1))
+		}
+	}
+
+	if len(errors) > 0 {
+		return fmt.Errorf("invalid schedules:\n%s", strings.Join(errors, "\n"))
+	}
+
+	return nil
+}
+
+// start running configured schedules
+func startSchedules() {
+	for _, schedule := range schedules {
+		go runSchedule(schedule)
+	}
+}
+
+// check if given request was not made by a user (eg. scheduled ones)
+func isBackgroundRequest(request ExecuteRequest) bool {
+	return request.UserID == scheduleUserID
+}
+
+// push execute requests of given schedule periodically
+func runSchedule(schedule Schedule) {
+	for {
+		next := schedule.next(time.Now())
+		if next.IsZero() {
+			log.Printf("*** No next time for scheduled execution of '%s' (%s)", schedule.Script, schedule.describe())
+			return
+		}
+		time.Sleep(time.Until(next))
+
+		if isShuttingDown() {
+			return
+		}
+
+		request := ExecuteRequest{
+			UserID:         scheduleUserID,
+			ChatID:         schedule.ChatID,
+			ScriptName:     schedule.Script,
+			ScriptPath:     scripts[schedule.Script].Path,
+			MessageOptions: map[string]interface{}{},
+		}
+
+		// do not block when the queue is full
+		if _, queued := enqueueRequest(request); !queued {
+			log.Printf("*** Skipping scheduled execution of '%s': %s", schedule.Script, queueRejectedMessage())
+		}
+	}
+}
+
+// list configured schedules with their next times
+func schedulesMessage() string {
+	if len(schedules) <= 0 {
+		return messageNoSchedules
+	}
+
+	now := time.Now()
+	lines := []string{}
+	for i, schedule := range schedules {
+		line := fmt.Sprintf("#%d %s: %s", i+1, schedule.Script, schedule.describe())
+		if next := schedule.next(now); !next.IsZero() {
+			line += fmt.Sprintf(" (next: %s)", next.Format("2006-01-02 15:04"))
+		}
+		lines = append(lines, line)
+	}
+	return fmt.Sprintf(messageSchedulesFormat, strings.Join(lines, "\n"))
+}
@@ -0,0 +1,553 @@
+// configured scripts and their parameters
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	bot "github.com/meinside/telegram-bot-go"
+)
+
+const (
+	numParameterValuesPerRow = 3 // number of value buttons in a row
+
+	tempDirEnvName = "TMPDIR" // environment variable for the temp directory
+)
+
+var (
+	envNamePattern      = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	envReferencePattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`) // eg. ${HOME}
+)
+
+// Script struct for a configured script
+//
+// can be unmarshaled from a path string or an object
+type Script struct {
+	Path         string            `json:"path"`
+	Parameters   []ScriptParameter `json:"parameters,omitempty"`
+	ShowDuration bool              `json:"show_duration,omitempty"` // show how long the execution took
+
+	DisableNotification bool `json:"disable_notification,omitempty"` // send results of scheduled executions silently
+
+	RequireReason bool `json:"require_reason,omitempty"` // ask the user for a reason before execution
+
+	RequiresConfirmation bool `json:"requires_confirmation,omitempty"` // ask the user to confirm before execution (eg. for long-running scripts)
+
+	RTSPURL string `json:"rtsp_url,omitempty"` // url of an IP camera's stream, passed to the script as env var RTSP_URL
+
+	CaptionTemplate string `json:"caption_template,omitempty"` // template for captions, rendered with the json after #META:
+
+	TimeoutSeconds int `json:"timeout_seconds,omitempty"` // overrides the global timeout
+
+	Device string `json:"device,omitempty"` // device (camera) used by this script, for running scripts of different devices concurrently
+
+	TeardownCommand string `json:"teardown_command,omitempty"` // overrides the global teardown command
+
+	Env map[string]string `json:"env,omitempty"` // environment variables, overriding `script_env`
+
+	OutputFile string `json:"output_file,omitempty"` // file written by the script, sent instead of its stdout
+
+	WorkDir string `json:"work_dir,omitempty"` // working directory of the script (default: the directory of the script)
+
+	Streaming bool `json:"streaming,omitempty"` // emits multiple outputs as frames until stopped (see stream.go)
+
+	Interpreter string `json:"interpreter,omitempty"` // eg. "python3" or "bash", for running the script without its executable permission
+
+	// resource limits (linux only)
+	MemoryLimitMB   int `json:"memory_limit_mb,omitempty"`
+	CPULimitSeconds int `json:"cpu_limit_seconds,omitempty"`
+}
+
+// ScriptParameter struct for a parameter of a script
+//
+// passed to the script as: --Name Value
+type ScriptParameter struct {
+	Name   string   `json:"name"`
+	Values []string `json:"values"` // allowed values (enum)
+}
+
+// UnmarshalJSON unmarshals a script from a path string or an object
+func (s *Script) UnmarshalJSON(data []byte) error {
+	var path string
+	if err := json.Unmarshal(data, &path); err == nil {
+		*s = Script{Path: path}
+		return nil
+	}
+
+	type script Script // for avoiding recursion
+	var v script
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*s = Script(v)
+
+	return nil
+}
+
+// check if given value is allowed for this parameter
+func (p ScriptParameter) allows(value string) bool {
+	for _, v := range p.Values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// check arguments given inline (eg. --mode fast) against the allowed values of the script's parameters
+//
+// returns the parameter (nil for unknown ones) of the first invalid argument, or false when all of them are valid
+func invalidParameterArg(script Script, args []string) (param *ScriptParameter, invalid bool) {
+	for i := 0; i < len(args); i += 2 {
+		param = script.parameter(strings.TrimPrefix(args[i], "--"))
+		if !strings.HasPrefix(args[i], "--") || param == nil || i+1 >= len(args) || !param.allows(args[i+1]) {
+			return param, true
+		}
+	}
+	return nil, false
+}
+
+// parameter of this script with given name (nil if there is none)
+func (s Script) parameter(name string) *ScriptParameter {
+	for i := range s.Parameters {
+		if s.Parameters[i].Name == name {
+			return &s.Parameters[i]
+		}
+	}
+	return nil
+}
+
+// validate parameters of configured scripts
+func validateScripts(scripts map[string]Script) error {
+	for name, script := range scripts {
+		if script.MemoryLimitMB < 0 || script.CPULimitSeconds < 0 {
+			return fmt.Errorf("script '%s' has negative resource limits", name)
+		}
+		if script.TimeoutSeconds < 0 {
+			return fmt.Errorf("script '%s' has a negative timeout", name)
+		}
+		if err := validateEnv(script.Env); err != nil {
+			return fmt.Errorf("script '%s' has invalid env: %s", name, err)
+		}
+		if _, err := parseCaptionTemplate(script.CaptionTemplate); err != nil {
+			return fmt.Errorf("script '%s' has an invalid caption template: %s", name, err)
+		}
+		if len(script.RTSPURL) > 0 {
+			if err := validateRTSPURL(script.RTSPURL); err != nil {
+				return fmt.Errorf("script '%s' has an invalid rtsp url: %s (%s)", name, maskURL(script.RTSPURL), err)
+			}
+		}
+
+		for _, param := range script.Parameters {
+			if len(param.Name) <= 0 {
+				return fmt.Errorf("script '%s' has a parameter without name", name)
+			}
+			if len(param.Values) <= 0 {
+				return fmt.Errorf("parameter '%s' of script '%s' has no values", param.Name, name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// check if given path is an executable regular file
+//
+// (scripts run with interpreters don't need to be executable)
+func validateScriptPath(path string, interpreted bool) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("not a regular file")
+	}
+	if !interpreted && info.Mode().Perm()&0111 == 0 {
+		return fmt.Errorf("not executable (chmod +x, and start it with a shebang like '#!/usr/bin/env python')")
+	}
+	return nil
+}
+
+// validate paths of the default, clip, and configured scripts
+//
+// (returns an error listing all bad paths)
+func validateScriptPaths(defaultPath, clipPath string, scripts map[string]Script) error {
+	paths := map[string]string{}
+	if len(defaultPath) > 0 {
+		paths["script_path"] = defaultPath
+	}
+	if len(clipPath) > 0 {
+		paths["clip_script_path"] = clipPath
+	}
+	for name, script := range scripts {
+		paths[fmt.Sprintf("scripts.%s", name)] = script.Path
+	}
+
+	interpreted := map[string]bool{}
+	bad := []string{}
+	for name, script := range scripts {
+		if len(script.Interpreter) > 0 {
+			interpreted[fmt.Sprintf("scripts.%s", name)] = true
+
+			if _, err := exec.LookPath(strings.Fields(script.Interpreter)[0]); err != nil {
+				bad = append(bad, fmt.Sprintf("scripts.%s.interpreter: '%s' (%s)", name, script.Interpreter, err))
+			}
+		}
+		if len(script.WorkDir) > 0 {
+			if info, err := os.Stat(script.WorkDir); err != nil {
+				bad = append(bad, fmt.Sprintf("scripts.%s.work_dir: '%s' (%s)", name, script.WorkDir, err))
+			} else if !info.IsDir() {
+				bad = append(bad, fmt.Sprintf("scripts.%s.work_dir: '%s' (not a directory)", name, script.WorkDir))
+			}
+		}
+	}
+
+	names := []string{}
+	for name := range paths {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := validateScriptPath(paths[name], interpreted[name]); err != nil {
+			bad = append(bad, fmt.Sprintf("%s: '%s' (%s)", name, paths[name], err))
+		}
+	}
+	if len(bad) > 0 {
+		sort.Strings(bad)
+		return fmt.Errorf("bad script paths:\n%s", strings.Join(bad, "\n"))
+	}
+
+	return nil
+}
+
+// check if given user can run the named script (empty name for the default script)
+//
+// admins and users without permissions can run all scripts
+func canRunScript(userID, name string) bool {
+	allowed, restricted := permissions[userID]
+	if !restricted || isAdminID(userID) {
+		return true
+	}
+
+	for _, v := range allowed {
+		if v == name {
+			return true
+		}
+	}
+	return false
+}
+
+// check if given url is a valid rtsp url
+func validateRTSPURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("failed to parse")
+	}
+	if u.Scheme != "rtsp" && u.Scheme != "rtsps" {
+		return fmt.Errorf("unsupported scheme: '%s'", u.Scheme)
+	}
+	if len(u.Hostname()) <= 0 {
+		return fmt.Errorf("no host")
+	}
+
+	return nil
+}
+
+// mask credentials in given url, for logging
+func maskURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return redactedString
+	}
+	if u.User != nil {
+		u.User = url.User("redacted") // (not escaped, unlike redactedString)
+	}
+	if len(u.RawQuery) > 0 {
+		// (values of queries can be tokens)
+		query := u.Query()
+		for key := range query {
+			query.Set(key, "redacted")
+		}
+		u.RawQuery = query.Encode()
+	}
+
+	return u.String()
+}
+
+// timeout of this script's execution (0 = no timeout)
+//
+// (streaming scripts run until stopped, unless they have their own timeouts)
+func (s Script) timeout() time.Duration {
+	if s.Streaming {
+		return time.Duration(s.TimeoutSeconds) * time.Second
+	}
+	return time.Duration(intOrDefault(s.TimeoutSeconds, timeoutSeconds)) * time.Second
+}
+
+// command and arguments for executing the script at given path (with its interpreter, if any)
+func (s Script) command(path string, args []string) (string, []string) {
+	interpreter := strings.Fields(s.Interpreter)
+	if len(interpreter) <= 0 {
+		return path, args
+	}
+
+	// (relative paths would be resolved from the working directory)
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+
+	return interpreter[0], append(append(interpreter[1:], path), args...)
+}
+
+// working directory for executing the script at given path
+func (s Script) workDir(path string) string {
+	return valueOrDefault(s.WorkDir, filepath.Dir(path))
+}
+
+// environment variables for executing this script
+func (s Script) environment() []string {
+	env := []string{}
+	for _, name := range s.envNames() {
+		env = append(env, name+"="+interpolateEnv(s.envValue(name)))
+	}
+	if len(s.RTSPURL) > 0 {
+		env = append(env, "RTSP_URL="+s.RTSPURL)
+	}
+	if tempDir != os.TempDir() && !s.hasEnv(tempDirEnvName) {
+		env = append(env, tempDirEnvName+"="+tempDir) // (let scripts write temporary files in the same directory)
+	}
+	return env
+}
+
+// check if given environment variable is set with `script_env` or the script's own
+func (s Script) hasEnv(name string) bool {
+	_, global := scriptEnv[name]
+	_, own := s.Env[name]
+	return global || own
+}
+
+// sorted names of environment variables from `script_env` and the script's own
+func (s Script) envNames() []string {
+	names := []string{}
+	for name := range scriptEnv {
+		if _, overridden := s.Env[name]; !overridden {
+			names = append(names, name)
+		}
+	}
+	for name := range s.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// (uninterpolated) value of an environment variable for the script
+func (s Script) envValue(name string) string {
+	if value, exists := s.Env[name]; exists {
+		return value
+	}
+	return scriptEnv[name]
+}
+
+// read the output file of a script, and remove it
+func readOutputFile(path string) ([]byte, error) {
+	if info, err := os.Stat(path); err == nil && maxOutputBytes > 0 && info.Size() > int64(maxOutputBytes) {
+		removeOutputFile(path)
+		return nil, errOutputTooLarge
+	}
+
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read output file: %s", err)
+	}
+	removeOutputFile(path)
+
+	return bytes, nil
+}
+
+// remove the output file of a script, if any
+func removeOutputFile(path string) {
+	if len(path) <= 0 {
+		return
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		log.Printf("*** Failed to remove output file: %s", err)
+	}
+}
+
+// check names of given environment variables
+func validateEnv(env map[string]string) error {
+	for name := range env {
+		if !envNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid name: '%s'", name)
+		}
+	}
+	return nil
+}
+
+// replace ${VAR}s in given value with the bot's environment variables
+//
+// (missing ones are replaced with empty strings)
+func interpolateEnv(value string) string {
+	return envReferencePattern.ReplaceAllStringFunc(value, func(ref string) string {
+		name := envReferencePattern.FindStringSubmatch(ref)[1]
+		v, exists := os.LookupEnv(name)
+		if !exists {
+			log.Printf("*** Environment variable not set: %s", name)
+		}
+		return v
+	})
+}
+
+// describe how given script would be executed, with secrets masked
+func previewScript(name string, script Script) string {
+	// command line, with placeholders for parameters
+	name, args := script.command(script.Path, nil)
+	command := append([]string{name}, args...)
+	for _, param := range script.Parameters {
+		command = append(command, "--"+param.Name, "<"+strings.Join(param.Values, "|")+">")
+	}
+
+	workDir := script.workDir(script.Path)
+
+	// (values are hidden, as they may have secrets)
+	vars := []string{}
+	for _, name := range script.envNames() {
+		vars = append(vars, name+"=***")
+	}
+	if len(script.RTSPURL) > 0 {
+		vars = append(vars, "RTSP_URL="+maskURL(script.RTSPURL))
+	}
+	env := "(none)"
+	if len(vars) > 0 {
+		env = strings.Join(vars, " ")
+	}
+
+	limits := "(none)"
+	if script.MemoryLimitMB > 0 || script.CPULimitSeconds > 0 {
+		limits = fmt.Sprintf("memory: %dMB, cpu: %ds (0 = unlimited)", script.MemoryLimitMB, script.CPULimitSeconds)
+	}
+
+	timeout := "(none)"
+	if t := script.timeout(); t > 0 {
+		timeout = t.String()
+	}
+
+	return fmt.Sprintf(messagePreviewFormat, name, strings.Join(command, " "), workDir, env, timeout, limits)
+}
+
+// return arguments which are not allowed by the argument pattern
+//
+// (arguments are passed to scripts directly without a shell, but are still restricted)
+func invalidArgs(args []string) []string {
+	invalid := []string{}
+	for _, arg := range args {
+		if !argumentPattern.MatchString(arg) {
+			invalid = append(invalid, arg)
+		}
+	}
+	return invalid
+}
+
+// convert chosen values of parameters to command line arguments
+func parameterArgs(script Script, values []string) []string {
+	args := []string{}
+	for i, value := range values {
+		if i < len(script.Parameters) {
+			args = append(args, "--"+script.Parameters[i].Name, value)
+		}
+	}
+	return args
+}
+
+// generate a message and an inline keyboard for choosing the value of a script's parameter
+func parameterKeyboard(name string, script Script, index int) (string, bot.InlineKeyboardMarkup) {
+	param := script.Parameters[index]
+
+	keyboard := [][]bot.InlineKeyboardButton{}
+	row := []bot.InlineKeyboardButton{}
+	for _, value := range param.Values {
+		data := callbackPrefixValue + value
+		row = append(row, bot.InlineKeyboardButton{Text: value, CallbackData: &data})
+
+		if len(row) >= numParameterValuesPerRow {
+			keyboard = append(keyboard, row)
+			row = []bot.InlineKeyboardButton{}
+		}
+	}
+	if len(row) > 0 {
+		keyboard = append(keyboard, row)
+	}
+
+	return fmt.Sprintf(messageChooseValue, name, param.Name, index+1, len(script.Parameters)), bot.InlineKeyboardMarkup{
+		InlineKeyboard: keyboard,
+	}
+}
+
+// build keyboards from given rows of labels (commands or script names)
+//
+// script names will be converted to: /execute SCRIPT_NAME
+func buildKeyboards(rows [][]string, scripts map[string]Script) ([][]bot.KeyboardButton, error) {
+	keyboards := [][]bot.KeyboardButton{}
+	errors := []string{}
+
+	for _, row := range rows {
+		texts := []string{}
+		for _, label := range row {
+			if command, _ := unprefixedCommand(label); commandHandlers[command] != nil {
+				texts = append(texts, prefixedCommand(command))
+			} else if _, exists := scripts[label]; exists {
+				texts = append(texts, prefixedCommand(commandExecute)+" "+label)
+			} else {
+				errors = append(errors, fmt.Sprintf("no such command or script: '%s'", label))
+			}
+		}
+		if len(texts) > 0 {
+			keyboards = append(keyboards, bot.NewKeyboardButtons(texts...))
+		}
+	}
+
+	if len(errors) > 0 {
+		return nil, fmt.Errorf("invalid keyboard:\n%s", strings.Join(errors, "\n"))
+	}
+
+	return keyboards, nil
+}
+
+// generate a message and a reply keyboard for entering the value of a script's parameter with a message
+func parameterPrompt(name string, script Script, index int) (string, bot.ReplyKeyboardMarkup) {
+	param := script.Parameters[index]
+
+	keyboard := [][]bot.KeyboardButton{}
+	row := []bot.KeyboardButton{}
+	for _, value := range param.Values {
+		row = append(row, bot.KeyboardButton{Text: value})
+
+		if len(row) >= numParameterValuesPerRow {
+			keyboard = append(keyboard, row)
+			row = []bot.KeyboardButton{}
+		}
+	}
+	if len(row) > 0 {
+		keyboard = append(keyboard, row)
+	}
+	keyboard = append(keyboard, []bot.KeyboardButton{{Text: commandCancel}})
+
+	return fmt.Sprintf(messageEnterValue, name, param.Name, index+1, len(script.Parameters), strings.Join(param.Values, ", ")), bot.ReplyKeyboardMarkup{
+		Keyboard:        keyboard,
+		ResizeKeyboard:  true,
+		OneTimeKeyboard: true,
+	}
+}
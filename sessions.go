@@ -0,0 +1,104 @@
+// persisting sessions across restarts
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"time"
+
+	bot "github.com/meinside/telegram-bot-go"
+)
+
+const (
+	defaultSessionsFilename = "sessions.json"
+)
+
+// PersistedSession struct for the parts of a session which survive restarts
+type PersistedSession struct {
+	LastExecutedAt  time.Time     `json:"last_executed_at,omitempty"`
+	LastScript      string        `json:"last_script,omitempty"`
+	LastArgs        []string      `json:"last_args,omitempty"`
+	CancelledBefore time.Time     `json:"cancelled_before,omitempty"`
+	ParseMode       bot.ParseMode `json:"parse_mode,omitempty"`
+}
+
+// last saved content of the sessions file (for skipping unchanged writes)
+var savedSessions []byte
+
+// default path of the sessions file (next to given config file)
+func defaultSessionsPath(configFile string) string {
+	return defaultStatePath(configFile, defaultSessionsFilename)
+}
+
+// load persisted sessions from the file into given sessions
+//
+// (sessions of users who are not allowed anymore are ignored, and a missing file is not an error)
+func loadSessions(sessions map[string]Session) error {
+	file, err := ioutil.ReadFile(sessionsPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	persisted := map[string]PersistedSession{}
+	if err := json.Unmarshal(file, &persisted); err != nil {
+		return err
+	}
+
+	for id, p := range persisted {
+		if session, exists := sessions[id]; exists {
+			session.LastExecutedAt = p.LastExecutedAt
+			session.LastScript = p.LastScript
+			session.LastArgs = p.LastArgs
+			session.CancelledBefore = p.CancelledBefore
+			session.ParseMode = p.ParseMode
+			sessions[id] = session
+		}
+	}
+	savedSessions = file
+
+	return nil
+}
+
+// save sessions to the file, if they were changed
+//
+// (should be called while holding pool's lock)
+func saveSessions() {
+	persisted := map[string]PersistedSession{}
+	for id, session := range pool.Sessions {
+		persisted[id] = PersistedSession{
+			LastExecutedAt:  session.LastExecutedAt,
+			LastScript:      session.LastScript,
+			LastArgs:        session.LastArgs,
+			CancelledBefore: session.CancelledBefore,
+			ParseMode:       session.ParseMode,
+		}
+	}
+
+	file, err := json.MarshalIndent(persisted, "", "  ")
+	if err != nil {
+		log.Printf("*** Failed to marshal sessions: %s", err)
+		return
+	}
+	if bytes.Equal(file, savedSessions) {
+		return
+	}
+
+	// write to a temporary file first, for not leaving a broken file behind
+	tmp := sessionsPath + ".tmp"
+	if err := ioutil.WriteFile(tmp, file, 0600); err != nil {
+		log.Printf("*** Failed to write sessions: %s", err)
+		return
+	}
+	if err := os.Rename(tmp, sessionsPath); err != nil {
+		log.Printf("*** Failed to save sessions: %s", err)
+		return
+	}
+	savedSessions = file
+}
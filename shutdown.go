@@ -0,0 +1,136 @@
+// graceful shutdown
+
+package main
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultShutdownGraceSeconds = 30 // how long in-flight executions are waited for
+	shutdownPollInterval        = 100 * time.Millisecond
+)
+
+// Shutdown struct for the state of shutdown
+type Shutdown struct {
+	ShuttingDown bool
+	Active       int // number of executions in progress
+	sync.Mutex
+}
+
+// state of shutdown
+var shutdown Shutdown
+
+// check if the bot is shutting down
+func isShuttingDown() bool {
+	shutdown.Lock()
+	defer shutdown.Unlock()
+
+	return shutdown.ShuttingDown
+}
+
+// mark the start of an execution (returns false when shutting down)
+func acquireExecution() bool {
+	shutdown.Lock()
+	defer shutdown.Unlock()
+
+	if shutdown.ShuttingDown {
+		return false
+	}
+	shutdown.Active++
+
+	return true
+}
+
+// mark the end of an execution
+func releaseExecution() {
+	shutdown.Lock()
+	defer shutdown.Unlock()
+
+	shutdown.Active--
+}
+
+// number of executions in progress
+func numActiveExecutions() int {
+	shutdown.Lock()
+	defer shutdown.Unlock()
+
+	return shutdown.Active
+}
+
+// message for a request which could not be queued
+func queueRejectedMessage() string {
+	if isShuttingDown() {
+		return messageShuttingDown
+	}
+	if isInMaintenance() {
+		return messageMaintenance
+	}
+	return messageQueueFull
+}
+
+// shut down gracefully on SIGINT or SIGTERM
+func handleSignals(b BotClient) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	sig := <-signals
+	log.Printf("Received signal: %s, shutting down...", sig)
+
+	// stop accepting new requests
+	shutdown.Lock()
+	shutdown.ShuttingDown = true
+	shutdown.Unlock()
+
+	// stop other bots too (they shut down gracefully by themselves)
+	signalChildBots(sig)
+
+	// notify users with queued requests
+	for drained := false; !drained; {
+		select {
+		case request := <-executeChannel:
+			atomic.AddInt32(&queueLength, -1)
+			finishPendingRequest(request.UserID)
+
+			notifyShutdown(b, request)
+		default:
+			drained = true
+		}
+	}
+
+	// wait for in-flight executions, for a limited time
+	deadline := time.Now().Add(time.Duration(shutdownGraceSeconds) * time.Second)
+	for numActiveExecutions() > 0 && time.Now().Before(deadline) {
+		time.Sleep(shutdownPollInterval)
+	}
+	if remaining := numActiveExecutions(); remaining > 0 {
+		log.Printf("*** Exiting with %d execution(s) still in progress", remaining)
+	}
+
+	pool.Lock()
+	saveSessions()
+	pool.Unlock()
+
+	history.save()
+
+	stopHealthServer()
+
+	waitChildBots()
+
+	log.Printf("Bye.")
+
+	os.Exit(0)
+}
+
+// tell the user of a queued request that it will not be executed
+func notifyShutdown(b BotClient, request ExecuteRequest) {
+	if sent := b.SendMessage(request.ChatID, messageShuttingDown, request.MessageOptions); !sent.Ok {
+		log.Printf("*** Failed to notify shutdown: %s", *sent.Description)
+	}
+}
@@ -0,0 +1,71 @@
+// status of the bot
+
+package main
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// time when the bot was started
+var startTime = time.Now()
+
+// LastRun struct for the result of the last execution
+type LastRun struct {
+	Script     string
+	UserID     string
+	FinishedAt time.Time
+	Succeeded  bool
+	sync.Mutex
+}
+
+// the last execution
+var lastRun LastRun
+
+// record the result of an execution as the last one
+func (r *LastRun) record(request ExecuteRequest, succeeded bool) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.Script = valueOrDefault(request.ScriptName, request.ScriptPath)
+	r.UserID = request.UserID
+	r.FinishedAt = time.Now()
+	r.Succeeded = succeeded
+}
+
+// describe the last execution
+func (r *LastRun) describe() string {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.FinishedAt.IsZero() {
+		return "(none)"
+	}
+
+	result := "succeeded"
+	if !r.Succeeded {
+		result = "failed"
+	}
+
+	return fmt.Sprintf("%s by %s, %s at %s", r.Script, r.UserID, result, r.FinishedAt.Format(time.RFC3339))
+}
+
+// number of running executions
+func numRunning() int {
+	runningLock.Lock()
+	defer runningLock.Unlock()
+
+	return len(running)
+}
+
+// describe the current status of the bot
+func statusMessage() string {
+	return fmt.Sprintf(messageStatusFormat,
+		time.Since(startTime).Round(time.Second),
+		atomic.LoadInt32(&queueLength),
+		numRunning(),
+		lastRun.describe(),
+	)
+}
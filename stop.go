@@ -0,0 +1,139 @@
+// stopping running executions
+
+package main
+
+import (
+	"os/exec"
+	"sync"
+)
+
+// RunningExecution struct for a script being executed
+type RunningExecution struct {
+	UserID string
+
+	cmd       *exec.Cmd
+	cancelled bool
+	sync.Mutex
+
+	OnProgress func(percent int)  // called with progresses printed by the script (if not nil)
+	OnFrame    func(frame []byte) // called with frames printed by a streaming script (if not nil)
+}
+
+// currently running executions
+var running = map[*RunningExecution]bool{}
+var runningLock sync.Mutex
+
+// register a new running execution of given user
+func startExecution(userID string) *RunningExecution {
+	runningLock.Lock()
+	defer runningLock.Unlock()
+
+	execution := &RunningExecution{UserID: userID}
+	running[execution] = true
+
+	return execution
+}
+
+// unregister given running execution
+func endExecution(execution *RunningExecution) {
+	runningLock.Lock()
+	defer runningLock.Unlock()
+
+	delete(running, execution)
+}
+
+// set the started command of this execution
+//
+// (the command is terminated right away if the execution was cancelled before start)
+func (e *RunningExecution) started(cmd *exec.Cmd) {
+	if e == nil {
+		return
+	}
+
+	e.Lock()
+	defer e.Unlock()
+
+	e.cmd = cmd
+	if e.cancelled {
+		e.terminate()
+	}
+}
+
+// number of running executions of given user
+func numRunningOf(userID string) int {
+	runningLock.Lock()
+	defer runningLock.Unlock()
+
+	count := 0
+	for execution := range running {
+		if execution.UserID == userID {
+			count++
+		}
+	}
+	return count
+}
+
+// cancel this execution
+//
+// (should be called while holding the execution's lock)
+func (e *RunningExecution) terminate() {
+	if e.cmd != nil && e.cmd.Process != nil {
+		if err := terminateProcessGroup(e.cmd); err != nil {
+			logEvent("*** Failed to terminate process", map[string]interface{}{"user_id": e.UserID, "error": err.Error()})
+		}
+	}
+}
+
+// check if this execution was cancelled
+func (e *RunningExecution) wasCancelled() bool {
+	if e == nil {
+		return false
+	}
+
+	e.Lock()
+	defer e.Unlock()
+
+	return e.cancelled
+}
+
+// stop running executions of given user
+//
+// (with `all`, admins stop executions of everyone)
+//
+// returns a message for the user
+func stopExecution(userID string, all bool) string {
+	runningLock.Lock()
+	executions := []*RunningExecution{}
+	for execution := range running {
+		executions = append(executions, execution)
+	}
+	runningLock.Unlock()
+
+	if len(executions) <= 0 {
+		return messageNothingRunning
+	}
+
+	stopped := 0
+	for _, execution := range executions {
+		if execution.UserID != userID && !(all && isAdminID(userID)) {
+			continue
+		}
+
+		execution.Lock()
+		if !execution.cancelled {
+			execution.cancelled = true
+			execution.terminate()
+
+			logEvent("Execution stopped", map[string]interface{}{"user_id": execution.UserID, "stopped_by": userID})
+		}
+		execution.Unlock()
+
+		stopped++
+	}
+
+	if stopped <= 0 {
+		return messageNotYourExecution
+	}
+
+	return messageStopping
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+)
+
+// register running executions of given users for a test
+func withRunningExecutions(t *testing.T, userIDs ...string) map[string]*RunningExecution {
+	savedRunning, savedAdmins := running, adminIds
+	t.Cleanup(func() { running, adminIds = savedRunning, savedAdmins })
+
+	running = map[*RunningExecution]bool{}
+	adminIds = []string{"admin"}
+
+	executions := map[string]*RunningExecution{}
+	for _, id := range userIDs {
+		executions[id] = startExecution(id)
+	}
+	return executions
+}
+
+func TestStopOnlyStopsOwnExecutions(t *testing.T) {
+	executions := withRunningExecutions(t, "alice", "bob", "admin")
+
+	if message := stopExecution("alice", false); message != messageStopping {
+		t.Errorf("expected the user's execution to be stopped, got: %q", message)
+	}
+	if message := stopExecution("admin", false); message != messageStopping {
+		t.Errorf("expected the admin's own execution to be stopped, got: %q", message)
+	}
+	if !executions["alice"].wasCancelled() || !executions["admin"].wasCancelled() {
+		t.Errorf("expected own executions to be cancelled")
+	}
+	if executions["bob"].wasCancelled() {
+		t.Errorf("expected an execution of another user not to be cancelled by a plain stop")
+	}
+}
+
+func TestStopAllByAdminStopsEveryone(t *testing.T) {
+	executions := withRunningExecutions(t, "alice", "bob")
+
+	if message := stopExecution("admin", false); message != messageNotYourExecution {
+		t.Errorf("expected a plain stop of an admin not to stop others, got: %q", message)
+	}
+	if message := stopExecution("alice", true); message != messageStopping || executions["bob"].wasCancelled() {
+		t.Errorf("expected a non-admin's stop all to stop only their own execution, got: %q", message)
+	}
+	if message := stopExecution("admin", true); message != messageStopping || !executions["bob"].wasCancelled() {
+		t.Errorf("expected an admin's stop all to stop everyone's executions, got: %q", message)
+	}
+}
@@ -0,0 +1,168 @@
+// streaming scripts, which emit multiple outputs until they are stopped
+//
+// a streaming script prints each of its outputs as a frame:
+//
+//	FRAME <length in bytes>\n
+//	<bytes of the output>
+//
+// eg. in python:
+//
+//	sys.stdout.buffer.write(b"FRAME %d\n" % len(jpeg))
+//	sys.stdout.buffer.write(jpeg)
+//	sys.stdout.buffer.flush()
+//
+// each frame is sent as soon as it is read, and can have header lines (eg. `CAPTION:`, `TELEGRAM-SEND:`) in it.
+// bytes outside frames are handled as the output of a normal script, after the script exits.
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	bot "github.com/meinside/telegram-bot-go"
+)
+
+const (
+	frameMarker         = "FRAME " // prefix of frame header lines, followed by the length of the frame
+	maxFrameHeaderBytes = 32       // lines longer than this are not frame headers
+)
+
+// FrameWriter struct for reading frames out of a streaming script's output
+type FrameWriter struct {
+	output   io.Writer // for bytes outside frames
+	pending  []byte
+	size     int // size of the frame being read (-1 when reading a header line)
+	limit    int // max size of a frame (no limit when <= 0)
+	exceeded bool
+	onFrame  func(frame []byte)
+	onExceed func()
+}
+
+// create a writer which passes frames to given function, and writes the rest to given output
+func newFrameWriter(output io.Writer, limit int, onFrame func(frame []byte), onExceed func()) *FrameWriter {
+	return &FrameWriter{
+		output:   output,
+		size:     -1,
+		limit:    limit,
+		onFrame:  onFrame,
+		onExceed: onExceed,
+	}
+}
+
+// Write reads frames out of given bytes
+func (w *FrameWriter) Write(p []byte) (n int, err error) {
+	if w.exceeded {
+		return len(p), nil
+	}
+
+	w.pending = append(w.pending, p...)
+	for {
+		if w.size < 0 {
+			// header line
+			i := bytes.IndexByte(w.pending, '\n')
+			if i < 0 {
+				if len(w.pending) > maxFrameHeaderBytes {
+					if _, err := w.output.Write(w.pending); err != nil {
+						return 0, err
+					}
+					w.pending = nil
+				}
+				return len(p), nil
+			}
+
+			line := w.pending[:i+1]
+			w.pending = w.pending[i+1:]
+
+			size, isHeader := parseFrameHeader(line)
+			if !isHeader {
+				if _, err := w.output.Write(line); err != nil {
+					return 0, err
+				}
+				continue
+			}
+			if w.limit > 0 && size > w.limit {
+				w.exceeded = true
+				w.pending = nil
+				if w.onExceed != nil {
+					w.onExceed()
+				}
+				return len(p), nil
+			}
+			w.size = size
+		} else {
+			// body of the frame
+			if len(w.pending) < w.size {
+				return len(p), nil
+			}
+
+			frame := append([]byte{}, w.pending[:w.size]...)
+			w.pending = w.pending[w.size:]
+			w.size = -1
+
+			w.onFrame(frame)
+		}
+	}
+}
+
+// flush writes the incomplete header line (if any) to the output
+//
+// (an incomplete frame is discarded)
+func (w *FrameWriter) flush() {
+	if w.size < 0 && len(w.pending) > 0 {
+		w.output.Write(w.pending)
+	} else if w.size >= 0 {
+		log.Printf("*** Discarding incomplete frame (%d/%d bytes)", len(w.pending), w.size)
+	}
+	w.pending = nil
+}
+
+// Exceeded returns whether a frame exceeded the limit
+func (w *FrameWriter) Exceeded() bool {
+	return w.exceeded
+}
+
+// parse given line as a frame header, eg. "FRAME 1234\n"
+func parseFrameHeader(line []byte) (size int, isHeader bool) {
+	line = bytes.TrimRight(line, "\r\n")
+	if !bytes.HasPrefix(line, []byte(frameMarker)) {
+		return 0, false
+	}
+
+	size, err := strconv.Atoi(string(line[len(frameMarker):]))
+	if err != nil || size < 0 {
+		return 0, false
+	}
+	return size, true
+}
+
+// send a frame from a streaming script
+func sendFrame(b BotClient, request ExecuteRequest, frame []byte) {
+	_, caption, sendAs, data := splitHeaders(frame)
+	caption = truncateCaption(caption)
+	options := optionsWithCaption(request.MessageOptions, caption)
+
+	mime := detectContentType(data)
+
+	var sent bot.APIResponseMessage
+	switch {
+	case sendAs == sendAsPhoto || (len(sendAs) <= 0 && isPhoto(mime)):
+		sent = b.SendPhoto(request.ChatID, bot.InputFileFromBytes(data), options)
+	case sendAs == sendAsVideo || (len(sendAs) <= 0 && strings.HasPrefix(mime, "video")):
+		sent = b.SendVideo(request.ChatID, bot.InputFileFromBytes(data), options)
+	case sendAs == sendAsAnimation || (len(sendAs) <= 0 && mime == mimeGIF):
+		sent = b.SendAnimation(request.ChatID, bot.InputFileFromBytes(data), options)
+	case sendAs == sendAsText || (len(sendAs) <= 0 && isPlainText(mime) && utf8.Valid(data)):
+		sent = b.SendMessage(request.ChatID, appendLine(string(data), caption), request.MessageOptions)
+	default:
+		sent = sendDocumentWithFilename(b, request.ChatID, data, documentFilename(mime), options)
+	}
+
+	if !sent.Ok {
+		logEvent("*** Failed to send frame", requestFields(request, map[string]interface{}{"error": *sent.Description}))
+	}
+}
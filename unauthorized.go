@@ -0,0 +1,65 @@
+// alerting admins of access attempts by users who are not allowed
+
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	bot "github.com/meinside/telegram-bot-go"
+)
+
+const (
+	defaultUnauthorizedAlertIntervalSeconds = 600 // min interval between alerts for the same user
+)
+
+// UnauthorizedAlerts struct for throttling alerts of unauthorized access attempts
+type UnauthorizedAlerts struct {
+	alertedAt map[int]time.Time // user id => time of the last alert
+	sync.Mutex
+}
+
+// alerts of unauthorized access attempts
+var unauthorizedAlerts = UnauthorizedAlerts{
+	alertedAt: map[int]time.Time{},
+}
+
+// check if an alert for given user can be sent now, and mark it as sent
+func (a *UnauthorizedAlerts) take(userID int, now time.Time) bool {
+	a.Lock()
+	defer a.Unlock()
+
+	interval := time.Duration(unauthorizedAlertIntervalSeconds) * time.Second
+
+	// forget old alerts
+	for id, at := range a.alertedAt {
+		if now.Sub(at) >= interval {
+			delete(a.alertedAt, id)
+		}
+	}
+
+	if _, alerted := a.alertedAt[userID]; alerted {
+		return false
+	}
+	a.alertedAt[userID] = now
+
+	return true
+}
+
+// alert admins of an access attempt with given message from a user who is not allowed
+//
+// (throttled per user, and does nothing when no admin chats are configured)
+func alertUnauthorized(b BotClient, message *bot.Message) {
+	if len(adminChatIDs) <= 0 || message.From == nil || !unauthorizedAlerts.take(message.From.ID, time.Now()) {
+		return
+	}
+
+	username := "(none)"
+	if message.From.Username != nil {
+		username = "@" + *message.From.Username
+	}
+	txt, _ := messageText(message)
+
+	go notifyAdmins(b, fmt.Sprintf(messageUnauthorizedFormat, message.From.ID, username, txt))
+}
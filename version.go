@@ -0,0 +1,22 @@
+// build info, embedded with -ldflags
+//
+// eg. go build -ldflags "-X main.version=1.2.3 -X main.gitCommit=$(git rev-parse --short HEAD) -X main.buildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// build info (set with -ldflags)
+var (
+	version   = "dev"
+	gitCommit = "unknown"
+	buildTime = "unknown"
+)
+
+// build info as a message
+func versionMessage() string {
+	return fmt.Sprintf(messageVersionFormat, version, gitCommit, buildTime, runtime.Version())
+}